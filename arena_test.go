@@ -0,0 +1,59 @@
+package twine
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCipherArena(t *testing.T) {
+
+	a := NewCipherArena(4)
+
+	var bs []*twineCipher
+	for i := 0; i < 10; i++ {
+		key := append([]byte(nil), tests[1].key...)
+		key[0] = byte(i)
+		b, err := a.New(key, WithTables())
+		if err != nil {
+			t.Fatal(err)
+		}
+		bs = append(bs, b.(*twineCipher))
+
+		want, _ := New(key)
+		x, y := make([]byte, 8), make([]byte, 8)
+		want.Encrypt(x, tests[1].plain)
+		b.Encrypt(y, tests[1].plain)
+		if !bytes.Equal(x, y) {
+			t.Errorf("cipher %d: Encrypt = %x, want %x", i, y, x)
+		}
+	}
+	if n := a.Len(); n != 10 {
+		t.Errorf("Len = %d, want 10", n)
+	}
+	if len(a.slabs) != 3 || bs[1] != &a.slabs[0][1] {
+		t.Errorf("ciphers not allocated from slabs")
+	}
+
+	if _, err := a.New(make([]byte, 8)); err == nil {
+		t.Errorf("8-byte key accepted")
+	}
+	if _, err := a.New(tests[0].key, WithOnTheFlyKeySchedule()); err == nil {
+		t.Errorf("on-the-fly cipher accepted")
+	}
+
+	var w Wiper = a
+	w.Wipe()
+	if a.Len() != 0 {
+		t.Errorf("Len after Wipe = %d", a.Len())
+	}
+	for i, b := range bs {
+		if b.rk != ([36][8]byte{}) {
+			t.Errorf("cipher %d not wiped", i)
+		}
+	}
+	mustPanic(t, "wiped arena cipher", func() { bs[9].Encrypt(make([]byte, 8), tests[1].plain) })
+
+	if _, err := a.New(tests[0].key); err != nil || a.Len() != 1 {
+		t.Errorf("arena not reusable after Wipe: %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+//go:build amd64 && !purego
+
+package twine
+
+// avx2Tables holds the S-box and a block shuffle, each repeated for both
+// 128-bit lanes, in the layout cryptBlocksAVX2 expects
+type avx2Tables struct {
+	sbox [32]byte
+	perm [32]byte
+}
+
+var (
+	useAVX2 = hasAVX2()
+
+	avx2Enc, avx2Dec avx2Tables
+)
+
+func init() {
+	for _, t := range []*avx2Tables{&avx2Enc, &avx2Dec} {
+		copy(t.sbox[:], sbox)
+		copy(t.sbox[16:], sbox)
+	}
+
+	// VPSHUFB gathers: position p of the next state takes nybble
+	// shufinv[p] when encrypting and shuf[p] when decrypting
+	for p := 0; p < 16; p++ {
+		avx2Enc.perm[p] = byte(shufinv[p])
+		avx2Enc.perm[p+16] = byte(shufinv[p])
+		avx2Dec.perm[p] = byte(shuf[p])
+		avx2Dec.perm[p+16] = byte(shuf[p])
+	}
+}
+
+//go:noescape
+func cryptBlocksAVX2(rk *[36][8]byte, tab *avx2Tables, dst, src *byte, n int)
+
+func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+
+func xgetbv() (eax, edx uint32)
+
+func hasAVX2() bool {
+	maxID, _, _, _ := cpuid(0, 0)
+	if maxID < 7 {
+		return false
+	}
+
+	_, _, ecx1, _ := cpuid(1, 0)
+	const osxsave, avx = 1 << 27, 1 << 28
+	if ecx1&(osxsave|avx) != osxsave|avx {
+		return false
+	}
+
+	// the OS must save the YMM registers
+	if xcr0, _ := xgetbv(); xcr0&6 != 6 {
+		return false
+	}
+
+	_, ebx7, _, _ := cpuid(7, 0)
+	return ebx7&(1<<5) != 0
+}
+
+// avx2Group is the number of bytes cryptBlocksAVX2 processes per iteration
+const avx2Group = 128
+
+// encryptBlocksAsm encrypts the longest prefix of src it can with the
+// assembly backend and returns its length.
+func (t *twineCipher) encryptBlocksAsm(dst, src []byte) int {
+	n := len(src) / avx2Group
+	if !useAVX2 || n == 0 {
+		return 0
+	}
+	cryptBlocksAVX2(&t.rk, &avx2Enc, &dst[0], &src[0], n)
+	return n * avx2Group
+}
+
+func (t *twineCipher) decryptBlocksAsm(dst, src []byte) int {
+	n := len(src) / avx2Group
+	if !useAVX2 || n == 0 {
+		return 0
+	}
+	cryptBlocksAVX2(&t.drk, &avx2Dec, &dst[0], &src[0], n)
+	return n * avx2Group
+}
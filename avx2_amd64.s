@@ -0,0 +1,149 @@
+//go:build amd64 && !purego
+
+#include "textflag.h"
+
+// Each block is unpacked to one nybble per byte, so a 128-bit lane holds one
+// block and VPSHUFB implements both the S-box and the block shuffle.  Every
+// iteration processes 16 blocks in Y0-Y7.
+//
+// Register use:
+//	Y10	round key, both lanes
+//	Y11	scratch
+//	Y12	0x0f mask
+//	Y13	block shuffle
+//	Y14	S-box
+//	Y15	moves F outputs from even to odd nybbles
+
+// shiftTab[2j+1] = 2j, shiftTab[2j] = zero
+DATA shiftTab<>+0x00(SB)/8, $0x0680048002800080
+DATA shiftTab<>+0x08(SB)/8, $0x0e800c800a800880
+DATA shiftTab<>+0x10(SB)/8, $0x0680048002800080
+DATA shiftTab<>+0x18(SB)/8, $0x0e800c800a800880
+GLOBL shiftTab<>(SB), RODATA|NOPTR, $32
+
+DATA maskTab<>+0x00(SB)/8, $0x0f0f0f0f0f0f0f0f
+DATA maskTab<>+0x08(SB)/8, $0x0f0f0f0f0f0f0f0f
+DATA maskTab<>+0x10(SB)/8, $0x0f0f0f0f0f0f0f0f
+DATA maskTab<>+0x18(SB)/8, $0x0f0f0f0f0f0f0f0f
+GLOBL maskTab<>(SB), RODATA|NOPTR, $32
+
+// multipliers to pack nybble pairs with VPMADDUBSW: 16*even + odd
+DATA maddTab<>+0x00(SB)/8, $0x0110011001100110
+DATA maddTab<>+0x08(SB)/8, $0x0110011001100110
+DATA maddTab<>+0x10(SB)/8, $0x0110011001100110
+DATA maddTab<>+0x18(SB)/8, $0x0110011001100110
+GLOBL maddTab<>(SB), RODATA|NOPTR, $32
+
+// x[2j+1] ^= S[x[2j] ^ rk[j]]
+#define ROUND(x) \
+	VPXOR   Y10, x, Y11; \
+	VPSHUFB Y11, Y14, Y11; \
+	VPSHUFB Y15, Y11, Y11; \
+	VPXOR   Y11, x, x
+
+// F followed by the block shuffle
+#define ROUNDP(x) \
+	ROUND(x); \
+	VPSHUFB Y13, x, x
+
+// load 4 blocks from off(SI) as nybbles into lo and hi
+#define UNPACK(off, lo, hi) \
+	VMOVDQU    off(SI), Y8; \
+	VPSRLW     $4, Y8, Y9; \
+	VPAND      Y12, Y9, Y9; \
+	VPAND      Y12, Y8, Y8; \
+	VPUNPCKLBW Y8, Y9, lo; \
+	VPUNPCKHBW Y8, Y9, hi
+
+// pack the nybbles in lo and hi and store 4 blocks to off(DI)
+#define PACK(off, lo, hi) \
+	VPMADDUBSW Y11, lo, lo; \
+	VPMADDUBSW Y11, hi, hi; \
+	VPACKUSWB  hi, lo, lo; \
+	VMOVDQU    lo, off(DI)
+
+// func cryptBlocksAVX2(rk *[36][8]byte, tab *avx2Tables, dst, src *byte, n int)
+TEXT ·cryptBlocksAVX2(SB), NOSPLIT, $0-40
+	MOVQ rk+0(FP), R8
+	MOVQ tab+8(FP), AX
+	MOVQ dst+16(FP), DI
+	MOVQ src+24(FP), SI
+	MOVQ n+32(FP), CX
+
+	VMOVDQU 0(AX), Y14
+	VMOVDQU 32(AX), Y13
+	VMOVDQU shiftTab<>(SB), Y15
+	VMOVDQU maskTab<>(SB), Y12
+
+loop:
+	TESTQ CX, CX
+	JZ    done
+
+	UNPACK(0, Y0, Y1)
+	UNPACK(32, Y2, Y3)
+	UNPACK(64, Y4, Y5)
+	UNPACK(96, Y6, Y7)
+
+	MOVQ R8, R10
+	MOVQ $35, BX
+
+round:
+	VPBROADCASTQ (R10), X10
+	VPMOVZXBW    X10, Y10
+	ROUNDP(Y0)
+	ROUNDP(Y1)
+	ROUNDP(Y2)
+	ROUNDP(Y3)
+	ROUNDP(Y4)
+	ROUNDP(Y5)
+	ROUNDP(Y6)
+	ROUNDP(Y7)
+	ADDQ $8, R10
+	DECQ BX
+	JNZ  round
+
+	// last round has no shuffle
+	VPBROADCASTQ (R10), X10
+	VPMOVZXBW    X10, Y10
+	ROUND(Y0)
+	ROUND(Y1)
+	ROUND(Y2)
+	ROUND(Y3)
+	ROUND(Y4)
+	ROUND(Y5)
+	ROUND(Y6)
+	ROUND(Y7)
+
+	VMOVDQU maddTab<>(SB), Y11
+	PACK(0, Y0, Y1)
+	PACK(32, Y2, Y3)
+	PACK(64, Y4, Y5)
+	PACK(96, Y6, Y7)
+
+	ADDQ $128, SI
+	ADDQ $128, DI
+	DECQ CX
+	JMP  loop
+
+done:
+	VZEROUPPER
+	RET
+
+// func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+TEXT ·cpuid(SB), NOSPLIT, $0-24
+	MOVL eaxArg+0(FP), AX
+	MOVL ecxArg+4(FP), CX
+	CPUID
+	MOVL AX, eax+8(FP)
+	MOVL BX, ebx+12(FP)
+	MOVL CX, ecx+16(FP)
+	MOVL DX, edx+20(FP)
+	RET
+
+// func xgetbv() (eax, edx uint32)
+TEXT ·xgetbv(SB), NOSPLIT, $0-8
+	MOVL $0, CX
+	XGETBV
+	MOVL AX, eax+0(FP)
+	MOVL DX, edx+4(FP)
+	RET
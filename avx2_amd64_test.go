@@ -0,0 +1,54 @@
+//go:build amd64 && !purego
+
+package twine
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestAVX2(t *testing.T) {
+
+	if !useAVX2 {
+		t.Skip("AVX2 not available")
+	}
+
+	src := make([]byte, 5*avx2Group)
+	r := NewRand([32]byte{3})
+	for i := range src {
+		src[i] = byte(r.Uint64())
+	}
+
+	for _, tst := range tests {
+		b, _ := New(tst.key)
+		tw := b.(*twineCipher)
+
+		copy(src[8*21:], tst.plain)
+
+		want := make([]byte, len(src))
+		for i := 0; i < len(src); i += 8 {
+			tw.Encrypt(want[i:], src[i:i+8])
+		}
+
+		got := make([]byte, len(src))
+		if n := tw.encryptBlocksAsm(got, src); n != len(src) {
+			t.Fatalf("encryptBlocksAsm processed %d bytes, want %d", n, len(src))
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("AVX2 encryption differs from nybble implementation")
+		}
+		if !bytes.Equal(got[8*21:8*22], tst.cipher) {
+			t.Errorf("AVX2 encrypt failed:\ngot : % 02x\nwant: % 02x", got[8*21:8*22], tst.cipher)
+		}
+
+		// in place
+		if tw.decryptBlocksAsm(got, got); !bytes.Equal(got, src) {
+			t.Errorf("AVX2 decryption failed")
+		}
+
+		// short inputs are left to the caller
+		if n := tw.encryptBlocksAsm(got, src[:avx2Group-8]); n != 0 {
+			t.Errorf("encryptBlocksAsm processed %d bytes of a partial group", n)
+		}
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !amd64 || purego
+
+package twine
+
+const useAVX2 = false
+
+func (t *twineCipher) encryptBlocksAsm(dst, src []byte) int { return 0 }
+
+func (t *twineCipher) decryptBlocksAsm(dst, src []byte) int { return 0 }
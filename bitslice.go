@@ -0,0 +1,277 @@
+package twine
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// The bitsliced implementation processes 64 blocks at once.  Block k of a
+// batch occupies one bit lane of every word, and the state holds, for each
+// of the 16 nybble positions, four words carrying bits 0..3 of that nybble.
+// The S-box is evaluated as a boolean circuit, so there are no table lookups,
+// and the block shuffle is a renaming of words.
+
+const bitsliceBlocks = 64
+
+type bitslice [16][4]uint64
+
+// sboxBits evaluates the S-box on bitsliced nybbles: xi holds bit i of the
+// inputs and yi bit i of the outputs.  The expressions are the algebraic
+// normal form of table 1.
+func sboxBits(x0, x1, x2, x3 uint64) (y0, y1, y2, y3 uint64) {
+	x01 := x0 & x1
+	x02 := x0 & x2
+	x03 := x0 & x3
+	x12 := x1 & x2
+	x13 := x1 & x3
+	x23 := x2 & x3
+
+	y0 = x1 ^ x01 ^ x02 ^ x03 ^ x23 ^ x02&x3
+	y1 = x1 ^ x2 ^ x03 ^ x13 ^ x23 ^ x12&x3
+	y2 = ^(x0 ^ x2 ^ x3 ^ x02 ^ x03 ^ x13 ^ x23 ^ x01&x2)
+	y3 = ^(x0 ^ x2 ^ x01 ^ x12 ^ x01&x2 ^ x01&x3 ^ x12&x3)
+	return
+}
+
+// transpose64 transposes the 64x64 bit matrix a, numbering columns from the
+// most significant bit.
+func transpose64(a *[64]uint64) {
+	m := uint64(0x00000000ffffffff)
+	for j := uint(32); j != 0; j, m = j>>1, m^m<<(j>>1) {
+		for k := uint(0); k < 64; k = (k | j + 1) &^ j {
+			t := (a[k] ^ a[k|j]>>j) & m
+			a[k] ^= t
+			a[k|j] ^= t << j
+		}
+	}
+}
+
+// load transposes 64 blocks from src into the bitsliced state
+func (s *bitslice) load(src []byte) {
+	var a [64]uint64
+	for k := range a {
+		a[k] = binary.BigEndian.Uint64(src[8*k:])
+	}
+	transpose64(&a)
+
+	// column c is bit 3-c%4 of nybble c/4
+	for h := 0; h < 16; h++ {
+		for b := 0; b < 4; b++ {
+			s[h][b] = a[4*h+3-b]
+		}
+	}
+}
+
+// store is the inverse of load
+func (s *bitslice) store(dst []byte) {
+	var a [64]uint64
+	for h := 0; h < 16; h++ {
+		for b := 0; b < 4; b++ {
+			a[4*h+3-b] = s[h][b]
+		}
+	}
+	transpose64(&a)
+
+	for k := range a {
+		binary.BigEndian.PutUint64(dst[8*k:], a[k])
+	}
+}
+
+// round applies the F functions of round i to the state
+func (s *bitslice) round(rk *[8]byte) {
+	for j := 0; j < 8; j++ {
+		k := rk[j]
+		x := &s[2*j]
+		y0, y1, y2, y3 := sboxBits(
+			x[0]^-uint64(k&1),
+			x[1]^-uint64(k>>1&1),
+			x[2]^-uint64(k>>2&1),
+			x[3]^-uint64(k>>3&1),
+		)
+		y := &s[2*j+1]
+		y[0] ^= y0
+		y[1] ^= y1
+		y[2] ^= y2
+		y[3] ^= y3
+	}
+}
+
+func (s *bitslice) shuffle(p []int) {
+	var n bitslice
+	for h := 0; h < 16; h++ {
+		n[p[h]] = s[h]
+	}
+	*s = n
+}
+
+// encrypt64 encrypts 64 blocks from src into dst
+func (t *twineCipher) encrypt64(dst, src []byte) {
+	var s bitslice
+	s.load(src)
+	for i := 0; i < 35; i++ {
+		s.round(&t.rk[i])
+		s.shuffle(shuf)
+	}
+	s.round(&t.rk[35])
+	s.store(dst)
+}
+
+// decrypt64 decrypts 64 blocks from src into dst
+func (t *twineCipher) decrypt64(dst, src []byte) {
+	var s bitslice
+	s.load(src)
+	for i := 0; i < 35; i++ {
+		s.round(&t.drk[i])
+		s.shuffle(shufinv)
+	}
+	s.round(&t.drk[35])
+	s.store(dst)
+}
+
+// NewCTR returns a counter mode cipher.Stream equivalent to
+// cipher.NewCTR(t, iv), generating the keystream 64 blocks at a time with
+// EncryptBlocks.  crypto/cipher's NewCTR calls it automatically.
+//
+// Unlike the generic stream, it does not wrap the 64-bit counter: once the
+// block with counter 2^64-1 has been used, XORKeyStream panics with
+// ErrCounterOverflow rather than silently continuing from zero.
+//
+// The stream implements encoding.BinaryMarshaler and BinaryUnmarshaler so a
+// long-lived stream can be checkpointed and resumed.  The checkpoint holds
+// the keystream position and a 4-byte key check value, never key material:
+// to resume, create a stream with the same key and unmarshal the checkpoint
+// into it.  Unmarshaling into a stream under another key fails.
+func (t *twineCipher) NewCTR(iv []byte) cipher.Stream {
+	if len(iv) != 8 {
+		panic("cipher.NewCTR: IV length must equal block size")
+	}
+	return &ctr{
+		b:   t,
+		ctr: binary.BigEndian.Uint64(iv),
+	}
+}
+
+// wrappingCTR returns a CTR stream whose counter wraps modulo 2^64, for
+// modes such as EAX whose specification increments the whole block.
+func wrappingCTR(b cipher.Block, iv []byte) cipher.Stream {
+	t, ok := b.(*twineCipher)
+	if !ok {
+		return cipher.NewCTR(b, iv)
+	}
+	s := t.NewCTR(iv).(*ctr)
+	s.wrap = true
+	return s
+}
+
+type ctr struct {
+	b    *twineCipher
+	ctr  uint64 // counter of the next block to generate
+	base uint64 // counter of the first block in buf
+	n    int    // bytes of buf filled by the last refill
+	wrap bool   // counter wraps modulo 2^64 rather than overflowing
+	done bool   // the block with counter 2^64-1 has been generated
+	buf  [8 * bitsliceBlocks]byte
+	out  []byte
+}
+
+func (c *ctr) refill() {
+	if c.done {
+		panic(ErrCounterOverflow)
+	}
+	c.base = c.ctr
+	k := 0
+	for k < bitsliceBlocks {
+		binary.BigEndian.PutUint64(c.buf[8*k:], c.ctr)
+		k++
+		if c.ctr == math.MaxUint64 && !c.wrap {
+			c.done = true
+			break
+		}
+		c.ctr++
+	}
+	c.n = 8 * k
+	c.b.EncryptBlocks(c.buf[:c.n], c.buf[:c.n])
+	c.out = c.buf[:c.n]
+}
+
+func (c *ctr) XORKeyStream(dst, src []byte) {
+	if len(dst) < len(src) {
+		panic("crypto/cipher: output smaller than input")
+	}
+	for len(src) > 0 {
+		if len(c.out) == 0 {
+			c.refill()
+		}
+		n := len(src)
+		if n > len(c.out) {
+			n = len(c.out)
+		}
+		for i := 0; i < n; i++ {
+			dst[i] = src[i] ^ c.out[i]
+		}
+		c.out = c.out[n:]
+		dst, src = dst[n:], src[n:]
+	}
+}
+
+const (
+	ctrCheckpointVersion = 2
+	ctrCheckpointSize    = 14
+	ctrCheckLabel        = "twine CTR checkpoint"
+)
+
+var (
+	errCTRCheckpoint = errors.New("twine: invalid CTR checkpoint")
+	errCTRKey        = errors.New("twine: CTR checkpoint is for a different key")
+)
+
+// checkValue returns the key check value of the stream's cipher.  It is a
+// CMAC of a fixed label rather than E_K(0), which would also be the first
+// keystream block of a stream whose counter starts at zero.
+func (c *ctr) checkValue() []byte {
+	m := NewCMAC(c.b)
+	m.Write([]byte(ctrCheckLabel))
+	return m.Sum(nil)[:4]
+}
+
+// MarshalBinary returns the position of the stream: a version byte, the
+// big-endian counter of the block holding the next keystream byte, the
+// offset of that byte within the block, and the key check value.  It returns
+// ErrCounterOverflow if the stream's keystream is exhausted.
+func (c *ctr) MarshalBinary() ([]byte, error) {
+	next, off := c.ctr, 0
+	if len(c.out) != 0 {
+		used := c.n - len(c.out)
+		next = c.base + uint64(used/8)
+		off = used % 8
+	} else if c.done {
+		return nil, ErrCounterOverflow
+	}
+	data := make([]byte, 10, ctrCheckpointSize)
+	data[0] = ctrCheckpointVersion
+	binary.BigEndian.PutUint64(data[1:], next)
+	data[9] = byte(off)
+	return append(data, c.checkValue()...), nil
+}
+
+// UnmarshalBinary moves the stream to the position recorded by MarshalBinary.
+// It fails if the checkpoint was taken from a stream under another key.
+func (c *ctr) UnmarshalBinary(data []byte) error {
+	if len(data) != ctrCheckpointSize || data[0] != ctrCheckpointVersion || data[9] >= 8 {
+		return errCTRCheckpoint
+	}
+	if subtle.ConstantTimeCompare(data[10:], c.checkValue()) != 1 {
+		return errCTRKey
+	}
+	c.ctr = binary.BigEndian.Uint64(data[1:])
+	c.out = nil
+	c.done = false
+	if off := int(data[9]); off != 0 {
+		c.refill()
+		c.out = c.out[off:]
+	}
+	return nil
+}
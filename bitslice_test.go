@@ -0,0 +1,239 @@
+package twine
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"encoding"
+	"testing"
+)
+
+func TestSboxBits(t *testing.T) {
+
+	// lane x holds input x
+	var x [4]uint64
+	for v := 0; v < 16; v++ {
+		for b := range x {
+			x[b] |= uint64(v>>uint(b)&1) << uint(v)
+		}
+	}
+
+	y0, y1, y2, y3 := sboxBits(x[0], x[1], x[2], x[3])
+	for v := 0; v < 16; v++ {
+		got := byte(y0>>uint(v)&1 | (y1>>uint(v)&1)<<1 | (y2>>uint(v)&1)<<2 | (y3>>uint(v)&1)<<3)
+		if got != sbox[v] {
+			t.Errorf("S(%x)=%x, want %x", v, got, sbox[v])
+		}
+	}
+}
+
+func TestTranspose64(t *testing.T) {
+
+	var a [64]uint64
+	r := NewRand([32]byte{1})
+	for i := range a {
+		a[i] = r.Uint64()
+	}
+
+	tr := a
+	transpose64(&tr)
+	for i := uint(0); i < 64; i++ {
+		for j := uint(0); j < 64; j++ {
+			if a[i]>>(63-j)&1 != tr[j]>>(63-i)&1 {
+				t.Fatalf("bit (%d,%d) not transposed", i, j)
+			}
+		}
+	}
+}
+
+func TestBitsliced(t *testing.T) {
+
+	src := make([]byte, 8*bitsliceBlocks)
+	r := NewRand([32]byte{2})
+	for i := range src {
+		src[i] = byte(r.Uint64())
+	}
+
+	for _, tst := range tests {
+		b, _ := New(tst.key)
+		tw := b.(*twineCipher)
+
+		// one lane carries the known-answer vector
+		copy(src[8*17:], tst.plain)
+
+		want := make([]byte, len(src))
+		for i := 0; i < len(src); i += 8 {
+			tw.Encrypt(want[i:], src[i:i+8])
+		}
+
+		got := make([]byte, len(src))
+		tw.encrypt64(got, src)
+		if !bytes.Equal(got, want) {
+			t.Fatalf("bitsliced encryption differs from nybble implementation")
+		}
+		if !bytes.Equal(got[8*17:8*18], tst.cipher) {
+			t.Errorf("bitsliced encrypt failed:\ngot : % 02x\nwant: % 02x", got[8*17:8*18], tst.cipher)
+		}
+
+		tw.decrypt64(got, got)
+		if !bytes.Equal(got, src) {
+			t.Errorf("bitsliced decryption failed")
+		}
+	}
+}
+
+func TestBitslicedCTR(t *testing.T) {
+
+	b, _ := New(tests[1].key)
+	iv := []byte{0, 0, 0, 0xff, 0xff, 0xff, 0xff, 0xf0}
+
+	msg := make([]byte, 3*8*bitsliceBlocks+5)
+	for i := range msg {
+		msg[i] = byte(i)
+	}
+
+	// reference CTR over the plain cipher.Block, hiding the NewCTR method
+	want := make([]byte, len(msg))
+	cipher.NewCTR(struct{ cipher.Block }{b}, iv).XORKeyStream(want, msg)
+
+	got := make([]byte, len(msg))
+	s := cipher.NewCTR(b, iv)
+	if _, ok := s.(*ctr); !ok {
+		t.Fatalf("cipher.NewCTR did not use the bitsliced stream")
+	}
+	s.XORKeyStream(got[:7], msg[:7])
+	s.XORKeyStream(got[7:600], msg[7:600])
+	s.XORKeyStream(got[600:], msg[600:])
+
+	if !bytes.Equal(got, want) {
+		t.Errorf("bitsliced CTR differs from generic CTR")
+	}
+}
+
+func TestCTRCheckpoint(t *testing.T) {
+
+	b, _ := New(tests[0].key)
+	iv := []byte{0, 0, 0, 0, 0, 0, 0xff, 0xf0}
+
+	msg := make([]byte, 2*8*bitsliceBlocks+11)
+	want := make([]byte, len(msg))
+	cipher.NewCTR(b, iv).XORKeyStream(want, msg)
+
+	for _, split := range []int{0, 3, 8, 8*bitsliceBlocks - 1, 8 * bitsliceBlocks, 8*bitsliceBlocks + 5} {
+		s := cipher.NewCTR(b, iv)
+		got := make([]byte, len(msg))
+		s.XORKeyStream(got[:split], msg[:split])
+
+		data, err := s.(encoding.BinaryMarshaler).MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// resume from an unrelated IV; the checkpoint carries the position
+		r := cipher.NewCTR(b, make([]byte, 8))
+		if err := r.(encoding.BinaryUnmarshaler).UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		r.XORKeyStream(got[split:], msg[split:])
+
+		if !bytes.Equal(got, want) {
+			t.Errorf("split %d: resumed stream differs", split)
+		}
+	}
+
+	s := cipher.NewCTR(b, iv)
+	data, _ := s.(encoding.BinaryMarshaler).MarshalBinary()
+	u := s.(encoding.BinaryUnmarshaler)
+
+	badOff := append([]byte(nil), data...)
+	badOff[9] = 8
+	badVer := append([]byte(nil), data...)
+	badVer[0] = 1
+	for _, bad := range [][]byte{nil, make([]byte, 14), data[:10], badOff, badVer} {
+		if err := u.UnmarshalBinary(bad); err == nil {
+			t.Errorf("UnmarshalBinary(%x) succeeded", bad)
+		}
+	}
+
+	// a checkpoint does not resume a stream under another key
+	other, _ := New(tests[1].key)
+	r := cipher.NewCTR(other, iv).(encoding.BinaryUnmarshaler)
+	if err := r.UnmarshalBinary(data); err != errCTRKey {
+		t.Errorf("UnmarshalBinary under another key: got %v, want %v", err, errCTRKey)
+	}
+	if err := u.UnmarshalBinary(data); err != nil {
+		t.Errorf("UnmarshalBinary under the same key: %v", err)
+	}
+}
+
+func TestCTROverflow(t *testing.T) {
+
+	b, _ := New(tests[1].key)
+	iv := []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x9c}
+
+	// the last 100 blocks of the counter space are usable
+	msg := make([]byte, 100*8)
+	want := make([]byte, len(msg))
+	cipher.NewCTR(struct{ cipher.Block }{b}, iv).XORKeyStream(want, msg)
+
+	s := cipher.NewCTR(b, iv)
+	got := make([]byte, len(msg))
+	s.XORKeyStream(got[:3], msg[:3])
+
+	// a checkpoint taken before the end still resumes
+	data, err := s.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := cipher.NewCTR(b, make([]byte, 8))
+	if err := r.(encoding.BinaryUnmarshaler).UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	s.XORKeyStream(got[3:], msg[3:])
+	if !bytes.Equal(got, want) {
+		t.Errorf("CTR differs from generic CTR before the counter overflows")
+	}
+
+	if _, err := s.(encoding.BinaryMarshaler).MarshalBinary(); err != ErrCounterOverflow {
+		t.Errorf("MarshalBinary of an exhausted stream: got %v, want %v", err, ErrCounterOverflow)
+	}
+	mustPanic(t, "XORKeyStream past counter 2^64-1", func() {
+		s.XORKeyStream(got[:1], msg[:1])
+	})
+
+	tail := make([]byte, len(msg)-3)
+	r.XORKeyStream(tail, msg[3:])
+	if !bytes.Equal(tail, want[3:]) {
+		t.Errorf("resumed stream differs before the counter overflows")
+	}
+	mustPanic(t, "resumed XORKeyStream past counter 2^64-1", func() {
+		r.XORKeyStream(got[:1], msg[:1])
+	})
+
+	// EAX wraps the counter as its specification requires
+	msg = make([]byte, 3*8*bitsliceBlocks+5)
+	want = make([]byte, len(msg))
+	cipher.NewCTR(struct{ cipher.Block }{b}, iv).XORKeyStream(want, msg)
+	got = make([]byte, len(msg))
+	wrappingCTR(b, iv).XORKeyStream(got, msg)
+	if !bytes.Equal(got, want) {
+		t.Errorf("wrapping CTR differs from generic CTR")
+	}
+}
+
+func BenchmarkEncrypt(b *testing.B) {
+	c, _ := New(tests[1].key)
+	var blk [8]byte
+	b.SetBytes(8)
+	for i := 0; i < b.N; i++ {
+		c.Encrypt(blk[:], blk[:])
+	}
+}
+
+func BenchmarkEncryptBlocks(b *testing.B) {
+	c, _ := New(tests[1].key)
+	buf := make([]byte, 8*bitsliceBlocks)
+	b.SetBytes(int64(len(buf)))
+	for i := 0; i < b.N; i++ {
+		c.(MultiBlock).EncryptBlocks(buf, buf)
+	}
+}
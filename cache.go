@@ -0,0 +1,188 @@
+package twine
+
+import (
+	"container/list"
+	"crypto/cipher"
+	"sync"
+	"time"
+)
+
+// Cache holds keyed ciphers by key ID, so services decrypting under many keys
+// don't expand the key schedule on every request.  Entries expire after a
+// fixed time-to-live, and the least recently used entry is evicted once the
+// cache is full.  A Cache is safe for concurrent use.
+//
+// Ciphers used through Use belong to the cache, which wipes them once they
+// have been dropped and their last user has returned.  Ciphers returned by
+// Get are shared with the caller and are never wiped by the cache.
+type Cache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	max     int
+	lru     *list.List
+	entries map[string]*list.Element
+
+	now func() time.Time
+}
+
+type cacheEntry struct {
+	id      string
+	b       cipher.Block
+	expires time.Time
+
+	refs    int  // calls to Use in progress
+	shared  bool // returned by Get
+	dropped bool
+}
+
+// NewCache returns a Cache whose entries live for at most ttl and which holds
+// at most maxEntries ciphers, which must be at least 1.
+func NewCache(ttl time.Duration, maxEntries int) *Cache {
+	if maxEntries < 1 {
+		panic("twine: cache must hold at least one cipher")
+	}
+	return &Cache{
+		ttl:     ttl,
+		max:     maxEntries,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+		now:     time.Now,
+	}
+}
+
+// Get returns the cipher for id.  On a miss, load is called to fetch the raw
+// key, which is copied and expanded; the slice load returns is not modified.
+// The cipher is shared with the caller, so the cache cannot wipe it; prefer
+// Use.
+func (c *Cache) Get(id string, load func(id string) ([]byte, error)) (cipher.Block, error) {
+	ent, err := c.get(id, load)
+	if err != nil {
+		return nil, err
+	}
+	ent.shared = true
+	c.mu.Unlock()
+	return ent.b, nil
+}
+
+// Use calls fn with the cipher for id, loading it as Get does on a miss.  fn
+// must not retain the cipher: once the entry has expired or been evicted or
+// removed, it is wiped as soon as no call to fn is using it.
+func (c *Cache) Use(id string, load func(id string) ([]byte, error), fn func(b cipher.Block) error) error {
+	ent, err := c.get(id, load)
+	if err != nil {
+		return err
+	}
+	ent.refs++
+	c.mu.Unlock()
+
+	err = fn(ent.b)
+
+	c.mu.Lock()
+	ent.refs--
+	c.release(ent)
+	c.mu.Unlock()
+
+	return err
+}
+
+// get returns the live entry for id with c.mu held, unless it fails
+func (c *Cache) get(id string, load func(id string) ([]byte, error)) (*cacheEntry, error) {
+
+	c.mu.Lock()
+	if e, ok := c.entries[id]; ok {
+		ent := e.Value.(*cacheEntry)
+		if c.now().Before(ent.expires) {
+			c.lru.MoveToFront(e)
+			return ent, nil
+		}
+		c.remove(e)
+	}
+	c.mu.Unlock()
+
+	// load and expand outside the lock; concurrent misses for the same id
+	// may both load, and the last one wins
+	stored, err := load(id)
+	if err != nil {
+		return nil, err
+	}
+	key := append([]byte(nil), stored...)
+	b, err := New(key)
+	Wipe(key)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+
+	if e, ok := c.entries[id]; ok {
+		c.remove(e)
+	}
+	now := c.now()
+	c.trim(now)
+	ent := &cacheEntry{id: id, b: b, expires: now.Add(c.ttl)}
+	c.entries[id] = c.lru.PushFront(ent)
+
+	for c.lru.Len() > c.max {
+		c.remove(c.lru.Back())
+	}
+
+	return ent, nil
+}
+
+// Remove drops id from the cache.
+func (c *Cache) Remove(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[id]; ok {
+		c.remove(e)
+	}
+}
+
+// Sweep drops every expired entry.  Expired entries are otherwise dropped
+// when they are next looked up or reach the least recently used end of the
+// cache; services that must not keep keys past their time-to-live should
+// call Sweep periodically.
+func (c *Cache) Sweep() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	for e := c.lru.Front(); e != nil; {
+		next := e.Next()
+		if !now.Before(e.Value.(*cacheEntry).expires) {
+			c.remove(e)
+		}
+		e = next
+	}
+}
+
+// Len returns the number of cached ciphers, including expired ones not yet
+// dropped.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// trim drops expired entries from the least recently used end
+func (c *Cache) trim(now time.Time) {
+	for e := c.lru.Back(); e != nil && !now.Before(e.Value.(*cacheEntry).expires); e = c.lru.Back() {
+		c.remove(e)
+	}
+}
+
+func (c *Cache) remove(e *list.Element) {
+	ent := e.Value.(*cacheEntry)
+	c.lru.Remove(e)
+	delete(c.entries, ent.id)
+	ent.dropped = true
+	c.release(ent)
+}
+
+// release wipes a dropped entry's cipher once nothing can be using it
+func (c *Cache) release(ent *cacheEntry) {
+	if ent.dropped && ent.refs == 0 && !ent.shared {
+		if w, ok := ent.b.(Wiper); ok {
+			w.Wipe()
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package twine
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+
+	now := time.Unix(0, 0)
+	c := NewCache(time.Minute, 2)
+	c.now = func() time.Time { return now }
+
+	loads := 0
+	load := func(id string) ([]byte, error) {
+		loads++
+		if id == "bad" {
+			return nil, errors.New("no such key")
+		}
+		return []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, byte(len(id))}, nil
+	}
+
+	b1, err := c.Get("a", load)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b2, _ := c.Get("a", load); b2 != b1 || loads != 1 {
+		t.Errorf("cache miss on fresh entry: loads=%d", loads)
+	}
+
+	// expiry
+	now = now.Add(2 * time.Minute)
+	if b2, _ := c.Get("a", load); b2 == b1 || loads != 2 {
+		t.Errorf("expired entry returned: loads=%d", loads)
+	}
+
+	// LRU eviction
+	c.Get("b", load)
+	c.Get("a", load)
+	c.Get("cc", load)
+	if c.Len() != 2 {
+		t.Errorf("Len()=%d, want 2", c.Len())
+	}
+	loads = 0
+	c.Get("a", load)
+	if loads != 0 {
+		t.Error("most recently used entry was evicted")
+	}
+	c.Get("b", load)
+	if loads != 1 {
+		t.Error("least recently used entry was not evicted")
+	}
+
+	if _, err := c.Get("bad", load); err == nil {
+		t.Error("expected load error")
+	}
+
+	c.Remove("a")
+	loads = 0
+	c.Get("a", load)
+	if loads != 1 {
+		t.Error("removed entry still cached")
+	}
+
+	mustPanic(t, "NewCache(0)", func() { NewCache(time.Minute, 0) })
+}
+
+func TestCacheUse(t *testing.T) {
+
+	now := time.Unix(0, 0)
+	c := NewCache(time.Minute, 2)
+	c.now = func() time.Time { return now }
+
+	stored := map[string][]byte{"a": tests[0].key, "b": tests[1].key, "c": tests[0].key}
+	for id, k := range stored {
+		stored[id] = append([]byte(nil), k...)
+	}
+	load := func(id string) ([]byte, error) { return stored[id], nil }
+
+	var a *twineCipher
+	c.Use("a", load, func(b cipher.Block) error {
+		a = b.(*twineCipher)
+		// evicted while in use: not wiped until fn returns
+		c.Use("b", load, func(cipher.Block) error { return nil })
+		c.Use("c", load, func(cipher.Block) error { return nil })
+		if a.wiped {
+			t.Errorf("cipher wiped while in use")
+		}
+		return nil
+	})
+	if !a.wiped {
+		t.Errorf("evicted cipher not wiped after use")
+	}
+	if !bytes.Equal(stored["a"], tests[0].key) {
+		t.Errorf("load's key slice was modified: %x", stored["a"])
+	}
+
+	// Sweep drops and wipes expired entries
+	var b *twineCipher
+	c.Use("b", load, func(x cipher.Block) error { b = x.(*twineCipher); return nil })
+	now = now.Add(2 * time.Minute)
+	c.Sweep()
+	if c.Len() != 0 || !b.wiped {
+		t.Errorf("Sweep: Len()=%d, wiped=%v", c.Len(), b.wiped)
+	}
+
+	// ciphers handed out by Get are never wiped
+	g, _ := c.Get("a", load)
+	c.Remove("a")
+	if g.(*twineCipher).wiped {
+		t.Errorf("cipher returned by Get was wiped")
+	}
+
+	want := errors.New("fn failed")
+	if err := c.Use("a", load, func(cipher.Block) error { return want }); err != want {
+		t.Errorf("Use = %v, want fn's error", err)
+	}
+}
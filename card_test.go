@@ -0,0 +1,52 @@
+package twine
+
+import "testing"
+
+func TestCard(t *testing.T) {
+
+	master, _ := New(tests[1].key)
+	uid := []byte{0x04, 0x52, 0x7a, 0x12, 0x9b, 0x30, 0x80}
+	card, _ := New(CardKey(master, uid))
+
+	v := ValueRecord{Value: -250, Counter: 9}
+	rec := v.Seal(card, uid)
+	if len(rec) != ValueRecordSize {
+		t.Fatalf("record is %d bytes", len(rec))
+	}
+
+	got, err := OpenValueRecord(card, uid, rec)
+	if err != nil || got != v {
+		t.Errorf("OpenValueRecord = %+v, %v, want %+v", got, err, v)
+	}
+
+	// copied to another card
+	uid2 := []byte{0x04, 0x52, 0x7a, 0x12, 0x9b, 0x30, 0x81}
+	if _, err := OpenValueRecord(card, uid2, rec); err != ErrAuthFailed {
+		t.Errorf("record on another UID: err = %v", err)
+	}
+
+	rec[3]++
+	if _, err := OpenValueRecord(card, uid, rec); err != ErrAuthFailed {
+		t.Errorf("modified value: err = %v", err)
+	}
+	if _, err := OpenValueRecord(card, uid, rec[:15]); err != ErrMalformedContainer {
+		t.Errorf("short record: err = %v", err)
+	}
+
+	rn, cn := []byte("reader01"), []byte("card0001")
+	tr := CardTranscript{
+		ReaderNonce: rn,
+		CardNonce:   cn,
+		CardProof:   CardProof(card, rn, cn),
+		ReaderProof: ReaderProof(card, rn, cn),
+	}
+	if err := tr.Verify(card); err != nil {
+		t.Errorf("valid transcript: %v", err)
+	}
+
+	// a card proof is not a reader proof
+	tr.ReaderProof = tr.CardProof
+	if err := tr.Verify(card); err != ErrAuthFailed {
+		t.Errorf("reflected proof: err = %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+package twine
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"hash"
+)
+
+// cbcmac is CBC-MAC with a choice of padding, an optional final encryption
+// (EMAC) and an optional length block prepended to the message
+type cbcmac struct {
+	b     cipher.Block
+	final cipher.Block // EMAC output cipher, or nil
+	pad10 bool         // ISO/IEC 9797-1 padding method 2 rather than 1
+
+	prefixed bool   // first block is the message length
+	length   uint64 // declared length, for a prefixed MAC
+	n        uint64 // bytes written
+
+	x   [8]byte
+	buf [8]byte
+	nx  int
+
+	wiped bool
+}
+
+func checkMACBlock(b cipher.Block) {
+	if b.BlockSize() != 8 {
+		panic("twine: CBC-MAC requires a 64-bit block cipher")
+	}
+}
+
+// NewCBCMAC returns a hash.Hash computing the raw CBC-MAC under b, with the
+// message zero-padded to a whole number of blocks (ISO/IEC 9797-1 padding
+// method 1, MAC algorithm 1).  It is provided for legacy protocols that
+// specify it: it is only secure for messages of a single fixed length, and
+// zero padding makes messages that differ in trailing zeros collide.  Prefer
+// NewCMAC.  The returned hash also implements Wiper.
+func NewCBCMAC(b cipher.Block) hash.Hash {
+	checkMACBlock(b)
+	return &cbcmac{b: b}
+}
+
+// NewLengthCBCMAC returns a CBC-MAC like NewCBCMAC that first processes the
+// message length in bytes as a big-endian 64-bit block, which makes it
+// secure for messages of varying length.  Exactly length bytes must be
+// written before calling Sum, which panics otherwise.
+func NewLengthCBCMAC(b cipher.Block, length uint64) hash.Hash {
+	checkMACBlock(b)
+	m := &cbcmac{b: b, prefixed: true, length: length}
+	m.Reset()
+	return m
+}
+
+// NewEMAC returns a hash.Hash computing EMAC, the encrypted CBC-MAC: the
+// message is padded with a single one bit and zeros (ISO/IEC 9797-1 padding
+// method 2), CBC-MACed under b1 and the result encrypted under b2.  b1 and
+// b2 must be keyed independently.  The returned hash also implements Wiper.
+func NewEMAC(b1, b2 cipher.Block) hash.Hash {
+	checkMACBlock(b1)
+	checkMACBlock(b2)
+	return &cbcmac{b: b1, final: b2, pad10: true}
+}
+
+func (m *cbcmac) Reset() {
+	if m.wiped {
+		panic(errWipedMode)
+	}
+	m.x = [8]byte{}
+	m.nx = 0
+	m.n = 0
+	if m.prefixed {
+		binary.BigEndian.PutUint64(m.x[:], m.length)
+		m.b.Encrypt(m.x[:], m.x[:])
+	}
+}
+
+// Wipe scrubs the chaining value and buffered input.  The MAC must not be
+// used afterwards: any further call panics.
+func (m *cbcmac) Wipe() {
+	Wipe(m.x[:])
+	Wipe(m.buf[:])
+	m.wiped = true
+}
+
+func (m *cbcmac) Size() int { return 8 }
+
+func (m *cbcmac) BlockSize() int { return 8 }
+
+func (m *cbcmac) Write(p []byte) (int, error) {
+	if m.wiped {
+		panic(errWipedMode)
+	}
+	n := len(p)
+	m.n += uint64(n)
+
+	for len(p) > 0 {
+		k := copy(m.buf[m.nx:], p)
+		m.nx += k
+		p = p[k:]
+		if m.nx == 8 {
+			m.block(m.buf[:])
+			m.nx = 0
+		}
+	}
+
+	return n, nil
+}
+
+func (m *cbcmac) block(b []byte) {
+	for i := range m.x {
+		m.x[i] ^= b[i]
+	}
+	m.b.Encrypt(m.x[:], m.x[:])
+}
+
+func (m *cbcmac) Sum(in []byte) []byte {
+	if m.wiped {
+		panic(errWipedMode)
+	}
+
+	if m.prefixed && m.n != m.length {
+		panic("twine: CBC-MAC message length differs from the declared length")
+	}
+
+	// work on a copy so the caller can keep writing
+	c := *m
+
+	var last [8]byte
+	copy(last[:], c.buf[:c.nx])
+	switch {
+	case c.pad10:
+		last[c.nx] = 0x80
+		c.block(last[:])
+	case c.nx > 0 || c.n == 0 && !c.prefixed:
+		// method 1 pads the empty message to one zero block, unless the
+		// length block already makes it non-empty
+		c.block(last[:])
+	}
+
+	if c.final != nil {
+		c.final.Encrypt(c.x[:], c.x[:])
+	}
+
+	return append(in, c.x[:]...)
+}
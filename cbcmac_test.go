@@ -0,0 +1,87 @@
+package twine
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"encoding/binary"
+	"testing"
+)
+
+// cbcRef returns the last block of the CBC encryption of m under a zero IV
+func cbcRef(b cipher.Block, m []byte) []byte {
+	out := make([]byte, len(m))
+	cipher.NewCBCEncrypter(b, make([]byte, 8)).CryptBlocks(out, m)
+	return out[len(out)-8:]
+}
+
+func TestCBCMAC(t *testing.T) {
+
+	b1, _ := New(tests[0].key)
+	b2, _ := New(tests[1].key)
+
+	msg := make([]byte, 40)
+	for i := range msg {
+		msg[i] = byte(i + 1)
+	}
+
+	for n := 0; n <= len(msg); n++ {
+		zpad := make([]byte, (n+7)/8*8)
+		if n == 0 {
+			zpad = make([]byte, 8)
+		}
+		copy(zpad, msg[:n])
+
+		opad := make([]byte, n/8*8+8)
+		copy(opad, msg[:n])
+		opad[n] = 0x80
+
+		lpad := make([]byte, 8+len(zpad))
+		binary.BigEndian.PutUint64(lpad, uint64(n))
+		copy(lpad[8:], zpad)
+		if n == 0 {
+			lpad = lpad[:8]
+		}
+
+		emac := cbcRef(b1, opad)
+		b2.Encrypt(emac, emac)
+
+		for _, tt := range []struct {
+			name string
+			m    interface {
+				Write([]byte) (int, error)
+				Sum([]byte) []byte
+				Reset()
+			}
+			want []byte
+		}{
+			{"CBC-MAC", NewCBCMAC(b1), cbcRef(b1, zpad)},
+			{"length CBC-MAC", NewLengthCBCMAC(b1, uint64(n)), cbcRef(b1, lpad)},
+			{"EMAC", NewEMAC(b1, b2), emac},
+		} {
+			tt.m.Write(msg[:n/2])
+			tt.m.Write(msg[n/2 : n])
+			if got := tt.m.Sum(nil); !bytes.Equal(got, tt.want) {
+				t.Errorf("%s of %d bytes = %x, want %x", tt.name, n, got, tt.want)
+			}
+			tt.m.Reset()
+			tt.m.Write(msg[:n])
+			if got := tt.m.Sum(nil); !bytes.Equal(got, tt.want) {
+				t.Errorf("%s of %d bytes after Reset = %x, want %x", tt.name, n, got, tt.want)
+			}
+		}
+	}
+
+	m := NewLengthCBCMAC(b1, 10)
+	m.Write(msg[:9])
+	mustPanic(t, "Sum with short message", func() { m.Sum(nil) })
+
+	e1, _ := New(tests[0].key)
+	e2, _ := New(tests[1].key)
+	e := NewEMAC(e1, e2)
+	e.Write(msg[:5])
+	e.(Wiper).Wipe()
+	if e.(*cbcmac).buf != [8]byte{} {
+		t.Errorf("buffered input not wiped")
+	}
+	mustPanic(t, "EMAC Sum after Wipe", func() { e.Sum(nil) })
+}
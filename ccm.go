@@ -0,0 +1,207 @@
+package twine
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+)
+
+// ccm implements CCM (NIST SP 800-38C) for any block size.  Only 64-bit
+// blocks are exposed; the generality lets the tests check it against the
+// AES examples of the specification.
+type ccm struct {
+	b         cipher.Block
+	bs        int
+	nonceSize int
+	tagSize   int
+	l         int       // size of the length field and counter, bs-1-nonceSize
+	budget    tagBudget // Open calls under the key
+	wiped     bool
+}
+
+// NewCCM returns b, which must have a 64-bit block, wrapped in CCM mode
+// adapted to an 8-byte block: the first block B0 and the counter blocks hold
+// a flags byte, the nonce and an (7-nonceSize)-byte length or counter field.
+//
+// nonceSize must be between 2 and 5 bytes, so messages may be up to
+// 2^(8*(7-nonceSize)) - 1 bytes: 16MB with a 4-byte nonce, 64KB with a
+// 5-byte one.  Seal panics on longer messages rather than let the counter
+// wrap.  tagSize must be 8, or 4 or 6 with AllowShortTags, when Open
+// returns ErrKeyExhausted once the key has opened as many messages as
+// AllowShortTags describes.  Nonces must never repeat under one key, and
+// with nonces this short they should come from a counter, not at random.
+// The AEAD also implements Wiper.
+func NewCCM(b cipher.Block, nonceSize, tagSize int, opts ...TagOption) (cipher.AEAD, error) {
+	if b.BlockSize() != 8 {
+		return nil, errors.New("twine: CCM requires a 64-bit block cipher")
+	}
+	if nonceSize < 2 || nonceSize > 5 {
+		return nil, errors.New("twine: invalid CCM nonce size")
+	}
+	if err := checkTagSize(tagSize, 8, opts); err != nil {
+		return nil, err
+	}
+	return newCCM(b, nonceSize, tagSize)
+}
+
+func newCCM(b cipher.Block, nonceSize, tagSize int) (*ccm, error) {
+	if tagSize < 4 || tagSize > b.BlockSize() || tagSize%2 != 0 {
+		return nil, errors.New("twine: invalid CCM tag size")
+	}
+	bs := b.BlockSize()
+	return &ccm{b: b, bs: bs, nonceSize: nonceSize, tagSize: tagSize, l: bs - 1 - nonceSize, budget: tagBudget{limit: tagLimit(tagSize)}}, nil
+}
+
+// Wipe marks the AEAD as wiped; CCM holds no key material of its own.  The
+// AEAD must not be used afterwards: Seal and Open panic.
+func (c *ccm) Wipe() {
+	c.wiped = true
+}
+
+func (c *ccm) NonceSize() int { return c.nonceSize }
+
+func (c *ccm) Overhead() int { return c.tagSize }
+
+// maxLen returns the length of the longest message the length field holds
+func (c *ccm) maxLen() uint64 {
+	if c.l >= 8 {
+		return ^uint64(0)
+	}
+	return 1<<(8*uint(c.l)) - 1
+}
+
+// block sets x to flags || nonce || v, v in the trailing l bytes
+func (c *ccm) block(x []byte, flags byte, nonce []byte, v uint64) {
+	x[0] = flags
+	copy(x[1:], nonce)
+	for i := c.bs - 1; i > c.nonceSize; i-- {
+		x[i] = byte(v)
+		v >>= 8
+	}
+}
+
+// mac returns the CBC-MAC of B0, the encoded additional data and the
+// plaintext
+func (c *ccm) mac(nonce, plaintext, ad []byte) []byte {
+
+	x := make([]byte, c.bs)
+	flags := byte((c.tagSize-2)/2<<3 | (c.l - 1))
+	if len(ad) > 0 {
+		flags |= 0x40
+	}
+	c.block(x, flags, nonce, uint64(len(plaintext)))
+	c.b.Encrypt(x, x)
+
+	blk := make([]byte, c.bs)
+	n := 0 // bytes pending in blk
+	feed := func(p []byte) {
+		for len(p) > 0 {
+			k := copy(blk[n:], p)
+			n += k
+			p = p[k:]
+			if n == c.bs {
+				subtle.XORBytes(x, x, blk)
+				c.b.Encrypt(x, x)
+				n = 0
+			}
+		}
+	}
+	pad := func() {
+		if n > 0 {
+			for i := n; i < c.bs; i++ {
+				blk[i] = 0
+			}
+			subtle.XORBytes(x, x, blk)
+			c.b.Encrypt(x, x)
+			n = 0
+		}
+	}
+
+	if len(ad) > 0 {
+		var hdr [10]byte
+		switch a := uint64(len(ad)); {
+		case a < 1<<16-1<<8:
+			binary.BigEndian.PutUint16(hdr[:], uint16(a))
+			feed(hdr[:2])
+		case a < 1<<32:
+			hdr[0], hdr[1] = 0xff, 0xfe
+			binary.BigEndian.PutUint32(hdr[2:], uint32(a))
+			feed(hdr[:6])
+		default:
+			hdr[0], hdr[1] = 0xff, 0xff
+			binary.BigEndian.PutUint64(hdr[2:], a)
+			feed(hdr[:10])
+		}
+		feed(ad)
+		pad()
+	}
+	feed(plaintext)
+	pad()
+
+	return x
+}
+
+// ctr returns S_0 and the counter mode stream positioned at counter block
+// A_1
+func (c *ccm) ctr(nonce []byte) ([]byte, cipher.Stream) {
+	a := make([]byte, c.bs)
+	c.block(a, byte(c.l-1), nonce, 0)
+	s := cipher.NewCTR(c.b, a)
+	s0 := make([]byte, c.bs)
+	s.XORKeyStream(s0, s0)
+	return s0, s
+}
+
+func (c *ccm) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if c.wiped {
+		panic(errWipedMode)
+	}
+	if len(nonce) != c.nonceSize {
+		panic("twine: incorrect nonce length given to CCM")
+	}
+	if uint64(len(plaintext)) > c.maxLen() {
+		panic("twine: message too large for CCM")
+	}
+
+	tag := c.mac(nonce, plaintext, additionalData)
+
+	ret, out := sliceForAppend(dst, len(plaintext)+c.tagSize)
+	s0, s := c.ctr(nonce)
+	s.XORKeyStream(out, plaintext)
+	subtle.XORBytes(out[len(plaintext):], tag[:c.tagSize], s0)
+
+	return ret
+}
+
+func (c *ccm) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if c.wiped {
+		panic(errWipedMode)
+	}
+	if len(nonce) != c.nonceSize {
+		panic("twine: incorrect nonce length given to CCM")
+	}
+	if err := c.budget.spend(); err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < c.tagSize || uint64(len(ciphertext)-c.tagSize) > c.maxLen() {
+		return nil, ErrAuthFailed
+	}
+
+	n := len(ciphertext) - c.tagSize
+	ret, out := sliceForAppend(dst, n)
+
+	s0, s := c.ctr(nonce)
+	s.XORKeyStream(out, ciphertext[:n])
+
+	tag := c.mac(nonce, out, additionalData)
+	subtle.XORBytes(tag, tag, s0)
+	if subtle.ConstantTimeCompare(tag[:c.tagSize], ciphertext[n:]) != 1 {
+		for i := range out {
+			out[i] = 0
+		}
+		return nil, ErrAuthFailed
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,72 @@
+package twine
+
+import (
+	"crypto/aes"
+	"encoding/hex"
+	"testing"
+)
+
+// TestCCMAES checks the construction against examples 1 and 2 of NIST
+// SP 800-38C, which use AES; there are no published 64-bit block vectors.
+func TestCCMAES(t *testing.T) {
+
+	b, _ := aes.NewCipher(unhex("404142434445464748494a4b4c4d4e4f"))
+
+	for _, tt := range []struct {
+		nonce, ad, pt string
+		tagSize       int
+		want          string
+	}{
+		{"10111213141516", "0001020304050607", "20212223", 4, "7162015b4dac255d"},
+		{"1011121314151617", "000102030405060708090a0b0c0d0e0f", "202122232425262728292a2b2c2d2e2f", 6,
+			"d2a1f0e051ea5f62081a7792073d593d1fc64fbfaccd"},
+	} {
+		nonce := unhex(tt.nonce)
+		c, err := newCCM(b, len(nonce), tt.tagSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ct := c.Seal(nil, nonce, unhex(tt.pt), unhex(tt.ad))
+		if got := hex.EncodeToString(ct); got != tt.want {
+			t.Errorf("Seal = %s, want %s", got, tt.want)
+		}
+		if pt, err := c.Open(nil, nonce, ct, unhex(tt.ad)); err != nil || hex.EncodeToString(pt) != tt.pt {
+			t.Errorf("Open = %x, %v", pt, err)
+		}
+	}
+}
+
+func TestCCM(t *testing.T) {
+
+	b, _ := New(tests[1].key)
+
+	for _, nonceSize := range []int{2, 5} {
+		for _, tagSize := range []int{4, 6, 8} {
+			a, err := NewCCM(b, nonceSize, tagSize, AllowShortTags())
+			if err != nil {
+				t.Fatal(err)
+			}
+			testAEAD(t, "CCM", a, nil)
+		}
+	}
+
+	for _, bad := range [][2]int{{1, 8}, {6, 8}, {4, 5}, {4, 10}, {4, 2}} {
+		if _, err := NewCCM(b, bad[0], bad[1], AllowShortTags()); err == nil {
+			t.Errorf("NewCCM(%d, %d) succeeded", bad[0], bad[1])
+		}
+	}
+	if _, err := NewCCM(b, 4, 6); err == nil {
+		t.Errorf("6-byte tag accepted without AllowShortTags")
+	}
+
+	// a 5-byte nonce leaves a 2-byte length field
+	a, _ := NewCCM(b, 5, 8)
+	nonce := make([]byte, 5)
+	a.Seal(nil, nonce, make([]byte, 1<<16-1), nil)
+	mustPanic(t, "Seal of 64KB", func() { a.Seal(nil, nonce, make([]byte, 1<<16), nil) })
+
+	w, _ := New(tests[1].key)
+	a, _ = NewCCM(w, 4, 8)
+	a.(Wiper).Wipe()
+	mustPanic(t, "CCM Seal after Wipe", func() { a.Seal(nil, make([]byte, 4), nil, nil) })
+}
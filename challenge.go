@@ -0,0 +1,140 @@
+package twine
+
+import (
+	"container/list"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/subtle"
+	"io"
+	"sync"
+	"time"
+)
+
+// ChallengeSize is the size of the challenges issued by a Challenger.
+const ChallengeSize = 8
+
+const deviceKeyLabel = "twine device key"
+
+// DeviceKey returns the 16-byte key of device id, derived from the fleet
+// master key.  It is provisioned onto the device, while the gateway keeps
+// only the master.
+func DeviceKey(master cipher.Block, id []byte) []byte {
+	return deriveKey(master, deviceKeyLabel, id)
+}
+
+// Respond returns a device's response to challenge: the CMAC under its
+// device key of the challenge followed by context, which binds the response
+// to a purpose, such as a gateway name or session ID.
+func Respond(device cipher.Block, challenge, context []byte) []byte {
+	m := NewCMAC(device)
+	m.Write(challenge)
+	m.Write(context)
+	return m.Sum(nil)
+}
+
+// Challenger issues challenges to devices and verifies their responses.
+// Each challenge is accepted at most once and only until it expires, so
+// recorded responses cannot be replayed.  A Challenger is safe for
+// concurrent use.
+type Challenger struct {
+	master cipher.Block
+	ttl    time.Duration
+	rand   io.Reader
+
+	mu          sync.Mutex
+	outstanding map[string]pending
+	issued      *list.List // challenges in the order issued, so expiring first
+
+	now func() time.Time
+}
+
+type pending struct {
+	id      string
+	expires time.Time
+}
+
+// NewChallenger returns a Challenger for devices keyed with DeviceKey(master,
+// id), whose challenges expire after ttl.  If r is nil, crypto/rand.Reader
+// is used.
+func NewChallenger(master cipher.Block, ttl time.Duration, r io.Reader) *Challenger {
+	if r == nil {
+		r = rand.Reader
+	}
+	return &Challenger{
+		master:      master,
+		ttl:         ttl,
+		rand:        r,
+		outstanding: make(map[string]pending),
+		issued:      list.New(),
+		now:         time.Now,
+	}
+}
+
+// Challenge returns a fresh challenge for device id.
+func (c *Challenger) Challenge(id []byte) ([]byte, error) {
+
+	challenge := make([]byte, ChallengeSize)
+	if _, err := io.ReadFull(c.rand, challenge); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	c.expire(now)
+	if _, ok := c.outstanding[string(challenge)]; ok {
+		return nil, ErrNonceReused
+	}
+	c.outstanding[string(challenge)] = pending{id: string(id), expires: now.Add(c.ttl)}
+	c.issued.PushBack(string(challenge))
+
+	return challenge, nil
+}
+
+// expire drops the challenges that have expired by now.  All challenges
+// share one ttl, so they expire in the order issued and only the front of
+// the queue need be examined; challenges already consumed by Verify are
+// skipped as they reach it.
+func (c *Challenger) expire(now time.Time) {
+	for e := c.issued.Front(); e != nil; e = c.issued.Front() {
+		k := e.Value.(string)
+		if p, ok := c.outstanding[k]; ok {
+			if now.Before(p.expires) {
+				return
+			}
+			delete(c.outstanding, k)
+		}
+		c.issued.Remove(e)
+	}
+}
+
+// Verify checks, in constant time, that response is device id's response
+// to challenge with context.  The challenge is consumed whether or not the
+// response is valid.  It returns ErrAuthFailed if the challenge was not
+// issued to id, has expired or was already used, or if the response is
+// wrong.
+func (c *Challenger) Verify(id, challenge, context, response []byte) error {
+
+	c.mu.Lock()
+	p, ok := c.outstanding[string(challenge)]
+	delete(c.outstanding, string(challenge))
+	c.mu.Unlock()
+
+	if !ok || p.id != string(id) || !c.now().Before(p.expires) {
+		return ErrAuthFailed
+	}
+
+	key := DeviceKey(c.master, id)
+	defer Wipe(key)
+	b, err := New(key)
+	if err != nil {
+		return err
+	}
+	defer b.(Wiper).Wipe()
+
+	if subtle.ConstantTimeCompare(Respond(b, challenge, context), response) != 1 {
+		return ErrAuthFailed
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package twine
+
+import (
+	"crypto/cipher"
+	"testing"
+	"time"
+)
+
+func TestChallenger(t *testing.T) {
+
+	master, _ := New(tests[1].key)
+	c := NewChallenger(master, time.Minute, nil)
+
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	id := []byte("sensor-17")
+	device, _ := New(DeviceKey(master, id))
+	ctx := []byte("gateway-a")
+
+	ch, err := c.Challenge(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := Respond(device, ch, ctx)
+	if err := c.Verify(id, ch, ctx, resp); err != nil {
+		t.Fatalf("valid response rejected: %v", err)
+	}
+	if err := c.Verify(id, ch, ctx, resp); err != ErrAuthFailed {
+		t.Errorf("replayed response: err = %v, want ErrAuthFailed", err)
+	}
+
+	other, _ := New(DeviceKey(master, []byte("sensor-18")))
+	for _, tt := range []struct {
+		name    string
+		dev     cipher.Block
+		respCtx []byte
+		id      []byte
+	}{
+		{"wrong device key", other, ctx, id},
+		{"wrong context", device, []byte("gateway-b"), id},
+		{"wrong id", other, ctx, []byte("sensor-18")},
+	} {
+		ch, _ := c.Challenge(id)
+		if err := c.Verify(tt.id, ch, ctx, Respond(tt.dev, ch, tt.respCtx)); err != ErrAuthFailed {
+			t.Errorf("%s: err = %v, want ErrAuthFailed", tt.name, err)
+		}
+	}
+
+	ch, _ = c.Challenge(id)
+	now = now.Add(time.Minute)
+	if err := c.Verify(id, ch, ctx, Respond(device, ch, ctx)); err != ErrAuthFailed {
+		t.Errorf("expired challenge: err = %v, want ErrAuthFailed", err)
+	}
+	if len(c.outstanding) != 0 {
+		t.Errorf("%d challenges outstanding after verification", len(c.outstanding))
+	}
+}
+
+func TestChallengerExpiry(t *testing.T) {
+
+	master, _ := New(tests[1].key)
+	c := NewChallenger(master, time.Minute, nil)
+
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	id := []byte("sensor-17")
+	device, _ := New(DeviceKey(master, id))
+
+	var old [][]byte
+	for i := 0; i < 10; i++ {
+		ch, _ := c.Challenge(id)
+		old = append(old, ch)
+	}
+	c.Verify(id, old[3], nil, Respond(device, old[3], nil))
+
+	now = now.Add(30 * time.Second)
+	fresh, _ := c.Challenge(id)
+
+	// the old challenges expire and are dropped by the next Challenge; the
+	// fresh one is still outstanding
+	now = now.Add(45 * time.Second)
+	c.Challenge(id)
+	if len(c.outstanding) != 2 || c.issued.Len() != 2 {
+		t.Errorf("%d challenges outstanding, %d queued, want 2 and 2", len(c.outstanding), c.issued.Len())
+	}
+	if err := c.Verify(id, old[0], nil, Respond(device, old[0], nil)); err != ErrAuthFailed {
+		t.Errorf("expired challenge: err = %v, want ErrAuthFailed", err)
+	}
+	if err := c.Verify(id, fresh, nil, Respond(device, fresh, nil)); err != nil {
+		t.Errorf("unexpired challenge rejected: %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package twine
+
+import (
+	"bytes"
+	"crypto/des"
+	"encoding/hex"
+	"testing"
+)
+
+func unhex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// TestCMACTDEA checks the construction against the three-key TDEA examples
+// of NIST SP 800-38B, there being no published TWINE-CMAC vectors.
+func TestCMACTDEA(t *testing.T) {
+
+	b, err := des.NewTripleDESCipher(unhex("8aa83bf8cbda10620bc1bf19fbb6cd58bc313d4a371ca8b5"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := unhex("6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e51")
+
+	for _, tt := range []struct {
+		n   int
+		tag string
+	}{
+		{0, "b7a688e122ffaf95"},
+		{20, "743ddbe0ce2dc2ed"},
+		{32, "33e6b1092400eae5"},
+	} {
+		m := NewCMAC(b)
+		m.Write(msg[:tt.n])
+		if got := hex.EncodeToString(m.Sum(nil)); got != tt.tag {
+			t.Errorf("CMAC(M[:%d]) = %s, want %s", tt.n, got, tt.tag)
+		}
+	}
+}
+
+func TestCMAC(t *testing.T) {
+
+	b, _ := New(tests[1].key)
+
+	msg := make([]byte, 50)
+	for i := range msg {
+		msg[i] = byte(i)
+	}
+
+	for n := 0; n <= len(msg); n++ {
+		m := NewCMAC(b)
+		m.Write(msg[:n])
+		want := m.Sum(nil)
+
+		// split writes and repeated Sum agree
+		m.Reset()
+		m.Write(msg[:n/3])
+		m.Sum(nil)
+		m.Write(msg[n/3 : n])
+		if got := m.Sum(nil); !bytes.Equal(got, want) {
+			t.Errorf("%d bytes: split write gives %x, want %x", n, got, want)
+		}
+
+		if !VerifyCMAC(b, msg[:n], want) || !VerifyCMAC(b, msg[:n], want[:4], AllowShortTags()) {
+			t.Errorf("%d bytes: VerifyCMAC rejected a valid tag", n)
+		}
+		if VerifyCMAC(b, msg[:n], want[:7]) {
+			t.Errorf("%d bytes: VerifyCMAC accepted a short tag without AllowShortTags", n)
+		}
+		want[0] ^= 1
+		if VerifyCMAC(b, msg[:n], want) {
+			t.Errorf("%d bytes: VerifyCMAC accepted a bad tag", n)
+		}
+		if VerifyCMAC(b, msg[:n], want[:3], AllowShortTags()) {
+			t.Errorf("%d bytes: VerifyCMAC accepted a 3-byte tag", n)
+		}
+	}
+}
+
+func TestCMACWipe(t *testing.T) {
+	b, _ := New(tests[0].key)
+	m := NewCMAC(b)
+	m.Write([]byte("secret"))
+	m.(Wiper).Wipe()
+
+	c := m.(*cmac)
+	if !bytes.Equal(c.k1, make([]byte, 8)) || !bytes.Equal(c.k2, make([]byte, 8)) || c.buf != [16]byte{} {
+		t.Errorf("subkeys or buffer not wiped")
+	}
+	mustPanic(t, "Sum after Wipe", func() { m.Sum(nil) })
+}
@@ -0,0 +1,185 @@
+// Command twineconform checks the twine package against the normative
+// statements of the TWINE specification that can be tested mechanically, and
+// prints a report suitable for auditors.  It exits non-zero if any check
+// fails.
+//
+// The reference is "TWINE: A Lightweight Block Cipher for Multiple
+// Platforms" (Suzaki, Minematsu, Morioka, Kobayashi; SAC 2012).
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/dgryski/go-twine"
+)
+
+type check struct {
+	ref  string
+	stmt string
+	run  func() error
+}
+
+// tables and vectors transcribed from the specification
+var (
+	specSbox = [16]byte{0xC, 0x0, 0xF, 0xA, 0x2, 0xB, 0x9, 0x5, 0x8, 0x3, 0xD, 0x7, 0x1, 0xE, 0x6, 0x4}
+
+	specShuf = [16]int{5, 0, 1, 4, 7, 12, 3, 8, 13, 6, 9, 2, 15, 10, 11, 14}
+
+	specCon = []byte{
+		0x01, 0x02, 0x04, 0x08, 0x10, 0x20, 0x03, 0x06, 0x0c, 0x18, 0x30, 0x23, 0x05, 0x0a, 0x14, 0x28, 0x13, 0x26,
+		0x0f, 0x1e, 0x3c, 0x3b, 0x35, 0x29, 0x11, 0x22, 0x07, 0x0e, 0x1c, 0x38, 0x33, 0x25, 0x09, 0x12, 0x24, 0x0b,
+	}
+
+	specVectors = []struct {
+		key, plain, cipher []byte
+		taps               []int // nybbles of the key forming RK^1
+	}{
+		{
+			[]byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x99},
+			[]byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef},
+			[]byte{0x7c, 0x1f, 0x0f, 0x80, 0xb1, 0xdf, 0x9c, 0x28},
+			[]int{1, 3, 4, 6, 13, 14, 15, 16},
+		},
+		{
+			[]byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x99, 0xAA, 0xBB, 0xCC, 0xDD, 0xEE, 0xFF},
+			[]byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef},
+			[]byte{0x97, 0x9F, 0xF9, 0xB3, 0x79, 0xB5, 0xA9, 0xB8},
+			[]int{2, 3, 12, 15, 17, 18, 28, 31},
+		},
+	}
+)
+
+var checks = []check{
+	{"Data proc.", "the block size is 64 bits", func() error {
+		b, err := twine.New(specVectors[0].key)
+		if err != nil {
+			return err
+		}
+		if bs := b.BlockSize(); bs != 8 {
+			return fmt.Errorf("BlockSize() = %d", bs)
+		}
+		return nil
+	}},
+
+	{"Key sched.", "keys are 80 or 128 bits; other sizes are rejected", func() error {
+		for n := 0; n <= 32; n++ {
+			_, err := twine.New(make([]byte, n))
+			if ok := n == 10 || n == 16; ok != (err == nil) {
+				return fmt.Errorf("%d-byte key: err = %v", n, err)
+			}
+		}
+		return nil
+	}},
+
+	{"Data proc.", "the cipher has 36 rounds", func() error {
+		_, tr, err := twine.EncryptTrace(specVectors[0].key, specVectors[0].plain)
+		if err != nil {
+			return err
+		}
+		if len(tr.States) != 36 || len(tr.RoundKeys) != 36 {
+			return fmt.Errorf("%d states, %d round keys", len(tr.States), len(tr.RoundKeys))
+		}
+		return nil
+	}},
+
+	{"Table 1", "the S-box matches the specification", func() error {
+		if s := twine.SBox(); s != specSbox {
+			return fmt.Errorf("got %x", s)
+		}
+		return nil
+	}},
+
+	{"Table 2", "the block shuffle and its inverse match the specification", func() error {
+		if p := twine.Shuffle(); p != specShuf {
+			return fmt.Errorf("got %v", p)
+		}
+		p, pi := twine.Shuffle(), twine.InvShuffle()
+		for h := range p {
+			if pi[p[h]] != h {
+				return fmt.Errorf("inverse shuffle wrong at %d", h)
+			}
+		}
+		return nil
+	}},
+
+	{"Table 3", "the round constants match the specification", func() error {
+		if con := twine.RoundConstants(len(specCon)); !bytes.Equal(con, specCon) {
+			return fmt.Errorf("got % 02x", con)
+		}
+		return nil
+	}},
+
+	{"Key sched.", "the first round key taps the specified key nybbles", func() error {
+		for _, v := range specVectors {
+			_, tr, err := twine.EncryptTrace(v.key, v.plain)
+			if err != nil {
+				return err
+			}
+			for j, tap := range v.taps {
+				want := v.key[tap/2] >> (4 * uint(1-tap%2)) & 0x0f
+				if tr.RoundKeys[0][j] != want {
+					return fmt.Errorf("%d-bit key: RK^1[%d] = %x, want WK%d = %x", 8*len(v.key), j, tr.RoundKeys[0][j], tap, want)
+				}
+			}
+		}
+		return nil
+	}},
+
+	{"Appendix", "encryption reproduces the test vectors", func() error {
+		for _, v := range specVectors {
+			b, err := twine.New(v.key)
+			if err != nil {
+				return err
+			}
+			var ct [8]byte
+			b.Encrypt(ct[:], v.plain)
+			if !bytes.Equal(ct[:], v.cipher) {
+				return fmt.Errorf("%d-bit key: got % 02x, want % 02x", 8*len(v.key), ct[:], v.cipher)
+			}
+		}
+		return nil
+	}},
+
+	{"Appendix", "decryption inverts the test vectors", func() error {
+		for _, v := range specVectors {
+			b, err := twine.New(v.key)
+			if err != nil {
+				return err
+			}
+			var pt [8]byte
+			b.Decrypt(pt[:], v.cipher)
+			if !bytes.Equal(pt[:], v.plain) {
+				return fmt.Errorf("%d-bit key: got % 02x, want % 02x", 8*len(v.key), pt[:], v.plain)
+			}
+		}
+		return nil
+	}},
+}
+
+func main() {
+
+	fmt.Println("TWINE specification conformance report")
+	fmt.Println()
+
+	failed := 0
+	for _, c := range checks {
+		status := "PASS"
+		err := c.run()
+		if err != nil {
+			status = "FAIL"
+			failed++
+		}
+		fmt.Printf("%s  %-11s %s\n", status, c.ref, c.stmt)
+		if err != nil {
+			fmt.Printf("      %v\n", err)
+		}
+	}
+
+	fmt.Printf("\n%d of %d checks passed\n", len(checks)-failed, len(checks))
+
+	if failed != 0 {
+		os.Exit(1)
+	}
+}
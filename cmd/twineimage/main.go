@@ -0,0 +1,70 @@
+// Command twineimage seals firmware images for bootloaders that verify them
+// with TWINE-CMAC, and verifies sealed images.
+//
+// Usage:
+//
+//	twineimage -key hex -version 3 -rollback 7 [-chunk 1024] <firmware.bin >firmware.img
+//	twineimage -key hex -verify [-min-rollback 7] <firmware.img >firmware.bin
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/dgryski/go-twine"
+)
+
+func main() {
+
+	keyHex := flag.String("key", "", "MAC key (hex, 10 or 16 bytes)")
+	version := flag.Uint("version", 0, "firmware version")
+	rollback := flag.Uint("rollback", 0, "anti-rollback counter")
+	chunk := flag.Uint("chunk", 1024, "chunk size in bytes")
+	verify := flag.Bool("verify", false, "verify a sealed image and write its data")
+	minRollback := flag.Uint("min-rollback", 0, "lowest rollback counter accepted by -verify")
+
+	flag.Parse()
+
+	key, err := hex.DecodeString(*keyHex)
+	if err != nil {
+		log.Fatalf("bad key: %v", err)
+	}
+	mac, err := twine.New(key)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *verify {
+		// hold the data until the whole image has verified, so nothing
+		// unauthenticated reaches stdout
+		var data bytes.Buffer
+		h, err := twine.VerifyImage(&data, bufio.NewReader(os.Stdin), mac, uint32(*minRollback))
+		if err != nil {
+			log.Fatal(err)
+		}
+		if _, err := data.WriteTo(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Fprintf(os.Stderr, "ok: version %d, rollback %d, %d bytes\n", h.Version, h.Rollback, h.Length)
+		return
+	}
+
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+	out := bufio.NewWriter(os.Stdout)
+	h := twine.ImageHeader{Version: uint32(*version), Rollback: uint32(*rollback), ChunkSize: uint32(*chunk)}
+	if err := twine.SealImage(out, mac, h, data); err != nil {
+		log.Fatal(err)
+	}
+	if err := out.Flush(); err != nil {
+		log.Fatal(err)
+	}
+}
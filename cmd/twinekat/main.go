@@ -0,0 +1,489 @@
+// Command twinekat snapshots the known-answer output of every primitive in
+// the twine package into a manifest, and verifies a later build against it,
+// so crypto output can be shown to be unchanged across toolchain upgrades.
+//
+// Each manifest section is the SHA-256 of a deterministic stream of outputs.
+// There are sections for every cipher backend, every mode and every sealed
+// format.  The manifest can be signed with an Ed25519 key; verification
+// requires the public key and fails on an unsigned manifest.
+//
+// Usage:
+//
+//	twinekat -write manifest.json [-signkey seed.hex]
+//	twinekat -verify manifest.json -pubkey pub.hex
+package main
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"hash"
+	"log"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/dgryski/go-twine"
+)
+
+type section struct {
+	Name    string `json:"name"`
+	Vectors int    `json:"vectors"`
+	SHA256  string `json:"sha256"`
+}
+
+type manifest struct {
+	Version   int       `json:"version"`
+	Toolchain string    `json:"toolchain"`
+	Sections  []section `json:"sections"`
+	Signature string    `json:"signature,omitempty"`
+}
+
+const (
+	manifestVersion = 2
+	vectors         = 1000
+)
+
+func main() {
+
+	write := flag.String("write", "", "write a manifest to this file")
+	verify := flag.String("verify", "", "verify against the manifest in this file")
+	signKey := flag.String("signkey", "", "file holding a hex Ed25519 seed to sign the manifest with")
+	pubKey := flag.String("pubkey", "", "file holding a hex Ed25519 public key to check the signature with")
+
+	flag.Parse()
+
+	switch {
+	case *write != "":
+		m := manifest{Version: manifestVersion, Toolchain: runtime.Version(), Sections: snapshot()}
+		if *signKey != "" {
+			seed, err := readHex(*signKey, ed25519.SeedSize)
+			if err != nil {
+				log.Fatal(err)
+			}
+			sig := ed25519.Sign(ed25519.NewKeyFromSeed(seed), signed(m.Sections))
+			m.Signature = hex.EncodeToString(sig)
+		}
+		out, _ := json.MarshalIndent(m, "", "  ")
+		if err := os.WriteFile(*write, append(out, '\n'), 0644); err != nil {
+			log.Fatal(err)
+		}
+
+	case *verify != "":
+		if err := check(*verify, *pubKey); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("ok")
+
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+func check(file, pubKey string) error {
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+
+	if pubKey == "" {
+		return errors.New("-pubkey is required to verify a manifest")
+	}
+	pub, err := readHex(pubKey, ed25519.PublicKeySize)
+	if err != nil {
+		return err
+	}
+	sig, err := hex.DecodeString(m.Signature)
+	if err != nil || !ed25519.Verify(pub, signed(m.Sections), sig) {
+		return errors.New("manifest signature invalid")
+	}
+
+	if m.Version != manifestVersion {
+		return fmt.Errorf("manifest version %d, want %d", m.Version, manifestVersion)
+	}
+
+	want := make(map[string]section)
+	for _, s := range m.Sections {
+		want[s.Name] = s
+	}
+
+	var failed []string
+	for _, s := range snapshot() {
+		w, ok := want[s.Name]
+		if !ok {
+			log.Printf("%s: not in manifest", s.Name)
+			continue
+		}
+		if w != s {
+			failed = append(failed, s.Name)
+		}
+		delete(want, s.Name)
+	}
+	for name := range want {
+		failed = append(failed, name+" (missing from build)")
+	}
+
+	if failed != nil {
+		return errors.New("output changed: " + strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+// signed returns the bytes covered by the signature
+func signed(s []section) []byte {
+	b, _ := json.Marshal(s)
+	return b
+}
+
+func readHex(file string, size int) ([]byte, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	b, err := hex.DecodeString(string(bytes.TrimSpace(data)))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != size {
+		return nil, fmt.Errorf("%s: want %d bytes, got %d", file, size, len(b))
+	}
+	return b, nil
+}
+
+// snapshot computes every section of the manifest
+func snapshot() []section {
+
+	var sections []section
+
+	add := func(name string, f func(h hash.Hash)) {
+		h := sha256.New()
+		f(h)
+		sections = append(sections, section{Name: name, Vectors: vectors, SHA256: hex.EncodeToString(h.Sum(nil))})
+	}
+
+	for _, size := range []int{10, 16} {
+		size := size
+
+		for _, be := range backends {
+			be := be
+
+			// Monte Carlo chain: each ciphertext becomes the next plaintext
+			// and feeds into the next key
+			add(fmt.Sprintf("twine-%d-block%s", size*8, be.suffix), func(h hash.Hash) {
+				key := make([]byte, size)
+				var blk, dec [8]byte
+				for i := 0; i < vectors; i++ {
+					b, _ := twine.New(key, be.opts...)
+					b.Encrypt(blk[:], blk[:])
+					b.Decrypt(dec[:], blk[:])
+					h.Write(blk[:])
+					h.Write(dec[:])
+					key[i%size] ^= blk[i%8]
+				}
+			})
+		}
+
+		// the multi-block path, over runs long enough to reach the
+		// bitsliced and assembly code
+		add(fmt.Sprintf("twine-%d-blocks", size*8), func(h hash.Hash) {
+			b, _ := twine.New(counting(size))
+			mb := b.(twine.MultiBlock)
+			for i := 0; i < vectors; i++ {
+				buf := counting(8 * (1 + i%200))
+				buf[0] = byte(i)
+				mb.EncryptBlocks(buf, buf)
+				h.Write(buf)
+				mb.DecryptBlocks(buf, buf)
+				h.Write(buf)
+			}
+		})
+	}
+
+	add("varblock", func(h hash.Hash) {
+		v, _ := twine.NewVarBlock(counting(16))
+		for i := 0; i < vectors; i++ {
+			in := counting(twine.MinVarBlockSize + i%twine.MaxVarBlockSize)
+			in[0] = byte(i)
+			out := make([]byte, len(in))
+			v.Encrypt(out, in)
+			h.Write(out)
+		}
+	})
+
+	add("dm", func(h hash.Hash) {
+		for i := 0; i < vectors; i++ {
+			d := twine.NewDM()
+			d.Write(counting(i % 100))
+			h.Write(d.Sum(nil))
+		}
+	})
+
+	add("keyed-dm", func(h hash.Hash) {
+		d, _ := twine.NewKeyedDM(counting(10))
+		for i := 0; i < vectors; i++ {
+			d.Reset()
+			d.Write(counting(i % 100))
+			h.Write(d.Sum(nil))
+		}
+	})
+
+	add("mgf", func(h hash.Hash) {
+		for i := 0; i < vectors; i++ {
+			out := make([]byte, i%50)
+			twine.MGF(out, counting(i%20))
+			h.Write(out)
+		}
+	})
+
+	add("rand", func(h hash.Hash) {
+		var seed [32]byte
+		copy(seed[:], counting(32))
+		r := twine.NewRand(seed)
+		var b [8]byte
+		for i := 0; i < vectors; i++ {
+			binary.BigEndian.PutUint64(b[:], r.Uint64())
+			h.Write(b[:])
+		}
+	})
+
+	add("shuffle", func(h hash.Hash) {
+		s, _ := twine.NewDatasetShuffler(counting(16), vectors)
+		var b [8]byte
+		s.Walk(0, func(_, idx uint64) bool {
+			binary.BigEndian.PutUint64(b[:], idx)
+			h.Write(b[:])
+			return true
+		})
+	})
+
+	// modes, under two fixed keys
+	b1, _ := twine.New(counting(16))
+	b2, _ := twine.New(counting(10))
+	iv := counting(8)
+
+	// msg returns the ith message, a whole number of blocks if whole is set
+	msg := func(i int, whole bool) []byte {
+		n := i % 100
+		if whole {
+			n = 8 * (1 + i%40)
+		}
+		m := counting(n)
+		if n > 0 {
+			m[0] = byte(i)
+		}
+		return m
+	}
+
+	add("cbc", func(h hash.Hash) {
+		for i := 0; i < vectors; i++ {
+			m := msg(i, true)
+			cipher.NewCBCEncrypter(b1, iv).CryptBlocks(m, m)
+			h.Write(m)
+		}
+	})
+
+	add("ctr", func(h hash.Hash) {
+		ctr := counting(8)
+		for i := 0; i < vectors; i++ {
+			m := msg(i, false)
+			ctr[7] = byte(i)
+			cipher.NewCTR(b1, ctr).XORKeyStream(m, m)
+			h.Write(m)
+		}
+	})
+
+	add("ctr-checkpoint", func(h hash.Hash) {
+		s := cipher.NewCTR(b1, iv)
+		for i := 0; i < vectors; i++ {
+			m := msg(i, false)
+			s.XORKeyStream(m, m)
+			cp, _ := s.(encoding.BinaryMarshaler).MarshalBinary()
+			h.Write(cp)
+		}
+	})
+
+	add("feedback", func(h hash.Hash) {
+		for i := 0; i < vectors; i++ {
+			m := msg(i, false)
+			twine.NewFeedbackStream(b1, iv, 1+i%8, twine.FeedbackCiphertext, false).XORKeyStream(m, m)
+			h.Write(m)
+		}
+	})
+
+	// single-key XEX masks start at 2 * E(tweak); manifests written before
+	// that fix differ in this section only
+	add("xex", func(h hash.Hash) {
+		x := twine.NewXEX(b1)
+		for i := 0; i < vectors; i++ {
+			m := msg(i, false)
+			if len(m) < 8 {
+				continue
+			}
+			x.Encrypt(m, m, uint64(i))
+			h.Write(m)
+		}
+	})
+
+	add("xts", func(h hash.Hash) {
+		x := twine.NewXTS(b1, b2)
+		for i := 0; i < vectors; i++ {
+			m := msg(i, false)
+			if len(m) < 8 {
+				continue
+			}
+			x.Encrypt(m, m, uint64(i))
+			h.Write(m)
+		}
+	})
+
+	add("lrw", func(h hash.Hash) {
+		l, _ := twine.NewLRW(b1, iv)
+		for i := 0; i < vectors; i++ {
+			m := msg(i, true)
+			l.Encrypt(m, m, uint64(i))
+			h.Write(m)
+		}
+	})
+
+	macs := []struct {
+		name string
+		new  func() hash.Hash
+	}{
+		{"cmac", func() hash.Hash { return twine.NewCMAC(b1) }},
+		{"cbc-mac", func() hash.Hash { return twine.NewCBCMAC(b1) }},
+		{"emac", func() hash.Hash { return twine.NewEMAC(b1, b2) }},
+		{"pmac", func() hash.Hash { return twine.NewPMAC(b1) }},
+		{"lightmac", func() hash.Hash { return twine.NewLightMAC(b1, b2, 2) }},
+	}
+	for _, mac := range macs {
+		mac := mac
+		add(mac.name, func(h hash.Hash) {
+			for i := 0; i < vectors; i++ {
+				m := mac.new()
+				m.Write(msg(i, mac.name == "cbc-mac"))
+				h.Write(m.Sum(nil))
+			}
+		})
+	}
+
+	eax, _ := twine.NewEAX(b1)
+	ccm, _ := twine.NewCCM(b1, 4, 8)
+	siv, _ := twine.NewSIV(b1, b2, 8)
+	aeads := []struct {
+		name string
+		a    cipher.AEAD
+	}{
+		{"eax", eax},
+		{"ccm", ccm},
+		{"siv", siv},
+	}
+	for _, ae := range aeads {
+		ae := ae
+		add(ae.name, func(h hash.Hash) {
+			nonce := counting(ae.a.NonceSize())
+			for i := 0; i < vectors; i++ {
+				nonce[0] = byte(i)
+				m := msg(i, false)
+				h.Write(ae.a.Seal(nil, nonce, m, m[:len(m)/2]))
+			}
+		})
+	}
+
+	// sealed formats
+	add("sealed-counter", func(h hash.Hash) {
+		for i := 0; i < vectors; i++ {
+			h.Write(twine.SealWithCounter(eax, nil, iv, uint64(i), msg(i, false), nil))
+		}
+	})
+
+	add("command", func(h hash.Hash) {
+		for i := 0; i < vectors; i++ {
+			c := twine.Command{ID: uint16(i), Scope: uint32(i) * 3, Expires: time.Unix(int64(i)<<20, 0), Seq: uint64(i), Payload: msg(i, false)}
+			h.Write(c.Seal(b1))
+		}
+	})
+
+	add("card", func(h hash.Hash) {
+		for i := 0; i < vectors; i++ {
+			uid := msg(i, false)
+			card, _ := twine.New(twine.CardKey(b1, uid))
+			h.Write(twine.ValueRecord{Value: int32(i) - 500, Counter: uint32(i)}.Seal(card, uid))
+			h.Write(twine.CardProof(card, iv, uid))
+			h.Write(twine.ReaderProof(card, iv, uid))
+		}
+	})
+
+	add("challenge", func(h hash.Hash) {
+		for i := 0; i < vectors; i++ {
+			dev, _ := twine.New(twine.DeviceKey(b1, msg(i, false)))
+			h.Write(twine.Respond(dev, iv, msg(i, true)))
+		}
+	})
+
+	add("rolling-code", func(h hash.Hash) {
+		r := twine.NewRollingCode(b1, 0)
+		var b [4]byte
+		for i := 0; i < vectors; i++ {
+			code, _ := r.Next()
+			binary.BigEndian.PutUint32(b[:], code)
+			h.Write(b[:])
+		}
+	})
+
+	add("log", func(h hash.Hash) {
+		s, _ := twine.NewLogSealer(b1, b2, bytes.NewReader(iv))
+		for i := 0; i < vectors; i++ {
+			rec, _ := s.Seal(msg(i, false))
+			h.Write(rec)
+		}
+		h.Write(s.Close())
+	})
+
+	add("image", func(h hash.Hash) {
+		for i := 0; i < vectors; i++ {
+			hdr := twine.ImageHeader{Version: uint32(i), Rollback: uint32(i) / 10, ChunkSize: uint32(1 + i%16)}
+			twine.SealImage(h, b1, hdr, msg(i, false))
+		}
+	})
+
+	return sections
+}
+
+// backends are the cipher configurations with a section of their own; the
+// default keeps the unsuffixed name of the original manifest
+var backends = []struct {
+	suffix string
+	opts   []twine.Option
+}{
+	{"", nil},
+	{"-tables", []twine.Option{twine.WithTables()}},
+	{"-consttime", []twine.Option{twine.WithConstantTime()}},
+	{"-onthefly", []twine.Option{twine.WithOnTheFlyKeySchedule()}},
+	{"-onthefly-consttime", []twine.Option{twine.WithOnTheFlyKeySchedule(), twine.WithConstantTime()}},
+}
+
+// counting returns the bytes 0, 1, ..., n-1
+func counting(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i)
+	}
+	return b
+}
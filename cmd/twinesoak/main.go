@@ -0,0 +1,319 @@
+// Command twinesoak repeatedly encrypts and decrypts random data under random
+// keys for a fixed duration, for qualifying releases.  Every backend (the
+// default, WithTables, WithConstantTime and the on-the-fly key schedule, and
+// the bitsliced or assembly EncryptBlocks path) is checked against the
+// reference EncryptTrace path, and every mode is round-tripped under each
+// backend and must produce the same output under all of them.
+//
+// Usage:
+//
+//	twinesoak [-duration 4h] [-workers N] [-report 1m]
+package main
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"hash"
+	"log"
+	mrand "math/rand"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dgryski/go-twine"
+)
+
+func main() {
+
+	duration := flag.Duration("duration", time.Hour, "how long to run")
+	workers := flag.Int("workers", runtime.NumCPU(), "number of concurrent workers")
+	report := flag.Duration("report", time.Minute, "progress report interval")
+
+	flag.Parse()
+
+	var iterations, failures uint64
+	deadline := time.Now().Add(*duration)
+
+	var wg sync.WaitGroup
+	for w := 0; w < *workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for time.Now().Before(deadline) {
+				if err := soak(); err != nil {
+					log.Println("FAIL:", err)
+					atomic.AddUint64(&failures, 1)
+				}
+				atomic.AddUint64(&iterations, 1)
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	tick := time.NewTicker(*report)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-tick.C:
+			log.Printf("iterations=%d failures=%d", atomic.LoadUint64(&iterations), atomic.LoadUint64(&failures))
+		case <-done:
+			log.Printf("done: iterations=%d failures=%d", iterations, failures)
+			if failures != 0 {
+				os.Exit(1)
+			}
+			return
+		}
+	}
+}
+
+// backends are the cipher configurations cross-checked by soak
+var backends = []struct {
+	name string
+	opts []twine.Option
+}{
+	{"default", nil},
+	{"tables", []twine.Option{twine.WithTables()}},
+	{"consttime", []twine.Option{twine.WithConstantTime()}},
+	{"onthefly", []twine.Option{twine.WithOnTheFlyKeySchedule()}},
+	{"onthefly-consttime", []twine.Option{twine.WithOnTheFlyKeySchedule(), twine.WithConstantTime()}},
+}
+
+// modes are run under every backend with two independently keyed ciphers;
+// each returns its output, which must round-trip and be the same for all
+// backends
+var modes = []struct {
+	name string
+	run  func(b1, b2 cipher.Block, iv, msg []byte) ([]byte, error)
+}{
+	{"CBC", func(b1, _ cipher.Block, iv, msg []byte) ([]byte, error) {
+		ct := make([]byte, len(msg))
+		pt := make([]byte, len(msg))
+		cipher.NewCBCEncrypter(b1, iv).CryptBlocks(ct, msg)
+		cipher.NewCBCDecrypter(b1, iv).CryptBlocks(pt, ct)
+		return ct, roundTrip(pt, msg)
+	}},
+	{"CTR", func(b1, _ cipher.Block, iv, msg []byte) ([]byte, error) {
+		ct := make([]byte, len(msg))
+		pt := make([]byte, len(msg))
+		cipher.NewCTR(b1, iv).XORKeyStream(ct, msg)
+		cipher.NewCTR(b1, iv).XORKeyStream(pt, ct)
+		return ct, roundTrip(pt, msg)
+	}},
+	{"XEX", func(b1, _ cipher.Block, iv, msg []byte) ([]byte, error) {
+		// with one key, the first mask must not be E(tweak), or decrypting
+		// a zero block would reveal it
+		var z, et [8]byte
+		binary.BigEndian.PutUint64(et[:], 42)
+		b1.Encrypt(et[:], et[:])
+		twine.NewXEX(b1).Decrypt(z[:], z[:], 42)
+		binary.BigEndian.PutUint64(z[:], binary.BigEndian.Uint64(z[:])^42)
+		if z == et {
+			return nil, errors.New("Decrypt(0, tweak) reveals E(tweak)")
+		}
+		return tweakable(twine.NewXEX(b1), msg)
+	}},
+	{"XTS", func(b1, b2 cipher.Block, iv, msg []byte) ([]byte, error) {
+		return tweakable(twine.NewXTS(b1, b2), msg)
+	}},
+	{"LRW", func(b1, _ cipher.Block, iv, msg []byte) ([]byte, error) {
+		l, err := twine.NewLRW(b1, iv)
+		if err != nil {
+			return nil, err
+		}
+		return tweakable(l, msg)
+	}},
+	{"CMAC", func(b1, _ cipher.Block, iv, msg []byte) ([]byte, error) {
+		tag := sum(twine.NewCMAC(b1), msg)
+		if !twine.VerifyCMAC(b1, msg, tag) {
+			return nil, errors.New("VerifyCMAC rejected a valid tag")
+		}
+		return tag, nil
+	}},
+	{"CBC-MAC", func(b1, _ cipher.Block, iv, msg []byte) ([]byte, error) {
+		return sum(twine.NewCBCMAC(b1), msg), nil
+	}},
+	{"EMAC", func(b1, b2 cipher.Block, iv, msg []byte) ([]byte, error) {
+		return sum(twine.NewEMAC(b1, b2), msg), nil
+	}},
+	{"PMAC", func(b1, _ cipher.Block, iv, msg []byte) ([]byte, error) {
+		return sum(twine.NewPMAC(b1), msg), nil
+	}},
+	{"LightMAC", func(b1, b2 cipher.Block, iv, msg []byte) ([]byte, error) {
+		return sum(twine.NewLightMAC(b1, b2, 2), msg), nil
+	}},
+	{"EAX", func(b1, _ cipher.Block, iv, msg []byte) ([]byte, error) {
+		a, err := twine.NewEAX(b1)
+		if err != nil {
+			return nil, err
+		}
+		return sealOpen(a, iv, msg)
+	}},
+	{"CCM", func(b1, _ cipher.Block, iv, msg []byte) ([]byte, error) {
+		a, err := twine.NewCCM(b1, 4, 8)
+		if err != nil {
+			return nil, err
+		}
+		return sealOpen(a, iv[:4], msg)
+	}},
+	{"SIV", func(b1, b2 cipher.Block, iv, msg []byte) ([]byte, error) {
+		a, err := twine.NewSIV(b1, b2, len(iv))
+		if err != nil {
+			return nil, err
+		}
+		return sealOpen(a, iv, msg)
+	}},
+}
+
+// soak runs one iteration of every check under fresh random keys
+func soak() error {
+
+	key := make([]byte, 10+6*mrand.Intn(2))
+	rand.Read(key)
+	key2 := make([]byte, len(key))
+	rand.Read(key2)
+
+	// the reference trace path, and a scalar encryption of a whole buffer
+	// to compare the multi-block paths against
+	var p [8]byte
+	rand.Read(p[:])
+	ref, _, err := twine.EncryptTrace(key, p[:])
+	if err != nil {
+		return err
+	}
+
+	msg := make([]byte, twine.MaxVarBlockSize+8*mrand.Intn(192))
+	rand.Read(msg)
+	iv := make([]byte, 8)
+	rand.Read(iv)
+
+	ref1, err := twine.New(key)
+	if err != nil {
+		return err
+	}
+	scalar := make([]byte, len(msg))
+	for i := 0; i < len(msg); i += 8 {
+		ref1.Encrypt(scalar[i:i+8], msg[i:i+8])
+	}
+
+	outputs := make([][]byte, len(modes))
+	for _, be := range backends {
+		b1, err := twine.New(key, be.opts...)
+		if err != nil {
+			return err
+		}
+		b2, err := twine.New(key2, be.opts...)
+		if err != nil {
+			return err
+		}
+
+		var c, d [8]byte
+		b1.Encrypt(c[:], p[:])
+		if !bytes.Equal(c[:], ref) {
+			return fmt.Errorf("%s: key %x plain %x: Encrypt %x != EncryptTrace %x", be.name, key, p, c, ref)
+		}
+		b1.Decrypt(d[:], c[:])
+		if d != p {
+			return fmt.Errorf("%s: key %x plain %x: block round-trip got %x", be.name, key, p, d)
+		}
+
+		if mb, ok := b1.(twine.MultiBlock); ok {
+			ct := make([]byte, len(msg))
+			mb.EncryptBlocks(ct, msg)
+			if !bytes.Equal(ct, scalar) {
+				return fmt.Errorf("%s: key %x: EncryptBlocks differs from Encrypt over %d blocks", be.name, key, len(msg)/8)
+			}
+			mb.DecryptBlocks(ct, ct)
+			if !bytes.Equal(ct, msg) {
+				return fmt.Errorf("%s: key %x: DecryptBlocks round-trip failed over %d blocks", be.name, key, len(msg)/8)
+			}
+		}
+
+		for i, m := range modes {
+			out, err := m.run(b1, b2, iv, msg)
+			if err != nil {
+				return fmt.Errorf("%s %s: key %x iv %x: %v", be.name, m.name, key, iv, err)
+			}
+			if outputs[i] == nil {
+				outputs[i] = out
+			} else if !bytes.Equal(out, outputs[i]) {
+				return fmt.Errorf("%s %s: key %x iv %x: output differs from %s", be.name, m.name, key, iv, backends[0].name)
+			}
+		}
+	}
+
+	// variable-length permutation
+	v, err := twine.NewVarBlock(key)
+	if err != nil {
+		return err
+	}
+	short := msg[:twine.MinVarBlockSize+mrand.Intn(twine.MaxVarBlockSize)]
+	vc := make([]byte, len(short))
+	vp := make([]byte, len(short))
+	v.Encrypt(vc, short)
+	v.Decrypt(vp, vc)
+	if !bytes.Equal(vp, short) {
+		return fmt.Errorf("key %x input %x: VarBlock round-trip failed", key, short)
+	}
+
+	return nil
+}
+
+func roundTrip(got, want []byte) error {
+	if !bytes.Equal(got, want) {
+		return errors.New("round-trip failed")
+	}
+	return nil
+}
+
+func sum(h hash.Hash, msg []byte) []byte {
+	h.Write(msg)
+	return h.Sum(nil)
+}
+
+// tweakable round-trips msg, minus a random tail so that ciphertext
+// stealing is exercised where the mode supports it, through a tweakable mode
+func tweakable(t interface {
+	Encrypt(dst, src []byte, tweak uint64)
+	Decrypt(dst, src []byte, tweak uint64)
+}, msg []byte) ([]byte, error) {
+	if _, ok := t.(*twine.XEX); ok {
+		msg = msg[:len(msg)-int(msg[0]%8)]
+	}
+	ct := make([]byte, len(msg))
+	pt := make([]byte, len(msg))
+	t.Encrypt(ct, msg, 42)
+	t.Decrypt(pt, ct, 42)
+	return ct, roundTrip(pt, msg)
+}
+
+// sealOpen seals msg, opens it, and checks that a corrupted copy is rejected
+func sealOpen(a cipher.AEAD, nonce, msg []byte) ([]byte, error) {
+	ad := msg[:len(msg)/3]
+	ct := a.Seal(nil, nonce, msg, ad)
+	pt, err := a.Open(nil, nonce, ct, ad)
+	if err != nil {
+		return nil, err
+	}
+	if err := roundTrip(pt, msg); err != nil {
+		return nil, err
+	}
+	bad := append([]byte(nil), ct...)
+	bad[int(msg[0])%len(bad)] ^= 1
+	if _, err := a.Open(nil, nonce, bad, ad); err == nil {
+		return nil, errors.New("corrupted ciphertext accepted")
+	}
+	return ct, nil
+}
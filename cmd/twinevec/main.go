@@ -0,0 +1,154 @@
+// Command twinevec writes a C header of TWINE test data for embedded ports:
+// for each key size, the key, its 36 round keys (one nybble per byte, in
+// the order of the specification) and a set of plaintext/ciphertext pairs.
+// The first pair is the specification's test vector; the rest are generated
+// deterministically.
+//
+// Arrays are declared with a storage attribute so they can live in flash:
+// PROGMEM on AVR (defined away elsewhere) by default, or e.g. __code for
+// 8051 compilers.
+//
+// Usage:
+//
+//	twinevec [-n 8] [-attr PROGMEM] [-o twine_vectors.h]
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/dgryski/go-twine"
+)
+
+var specKeys = [][]byte{
+	{0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x99},
+	{0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x99, 0xAA, 0xBB, 0xCC, 0xDD, 0xEE, 0xFF},
+}
+
+var specPlain = []byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef}
+
+func main() {
+
+	n := flag.Int("n", 8, "number of plaintext/ciphertext pairs per key size")
+	attr := flag.String("attr", "PROGMEM", "storage attribute for the arrays")
+	out := flag.String("o", "", "output file (default stdout)")
+
+	flag.Parse()
+
+	if *n < 1 {
+		fmt.Fprintln(os.Stderr, "twinevec: -n must be at least 1")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	bw := bufio.NewWriter(w)
+	if err := writeHeader(bw, *n, *attr); err != nil {
+		log.Fatal(err)
+	}
+	if err := bw.Flush(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func writeHeader(w io.Writer, n int, attr string) error {
+
+	fmt.Fprintln(w, "/* Code generated by twinevec. DO NOT EDIT. */")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "#ifndef TWINE_VECTORS_H")
+	fmt.Fprintln(w, "#define TWINE_VECTORS_H")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "#include <stdint.h>")
+	if attr == "PROGMEM" {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "#ifdef __AVR__")
+		fmt.Fprintln(w, "#include <avr/pgmspace.h>")
+		fmt.Fprintln(w, "#else")
+		fmt.Fprintln(w, "#define PROGMEM")
+		fmt.Fprintln(w, "#endif")
+	}
+
+	r := twine.NewRand([32]byte{'t', 'w', 'i', 'n', 'e', 'v', 'e', 'c'})
+
+	for _, key := range specKeys {
+		name := fmt.Sprintf("twine%d", 8*len(key))
+
+		b, err := twine.New(key)
+		if err != nil {
+			return err
+		}
+		_, tr, err := twine.EncryptTrace(key, specPlain)
+		if err != nil {
+			return err
+		}
+
+		plain := make([][]byte, n)
+		ct := make([][]byte, n)
+		for i := range plain {
+			plain[i] = make([]byte, 8)
+			if i == 0 {
+				copy(plain[i], specPlain)
+			} else {
+				for j := range plain[i] {
+					plain[i][j] = byte(r.Uint64())
+				}
+			}
+			ct[i] = make([]byte, 8)
+			b.Encrypt(ct[i], plain[i])
+		}
+
+		rk := make([][]byte, len(tr.RoundKeys))
+		for i := range rk {
+			rk[i] = tr.RoundKeys[i][:]
+		}
+
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "#define %s_NVECTORS %d\n", strings.ToUpper(name), n)
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "static const uint8_t %s_key[%d] %s = %s;\n", name, len(key), attr, row(key))
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "/* round keys RK^1..RK^36, one nybble per byte */\n")
+		writeTable(w, name+"_rk", attr, rk)
+		writeTable(w, name+"_plain", attr, plain)
+		writeTable(w, name+"_cipher", attr, ct)
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "#endif /* TWINE_VECTORS_H */")
+
+	return nil
+}
+
+func writeTable(w io.Writer, name, attr string, rows [][]byte) {
+	fmt.Fprintf(w, "static const uint8_t %s[%d][%d] %s = {\n", name, len(rows), len(rows[0]), attr)
+	for _, r := range rows {
+		fmt.Fprintf(w, "\t%s,\n", row(r))
+	}
+	fmt.Fprintln(w, "};")
+	fmt.Fprintln(w)
+}
+
+func row(b []byte) string {
+	s := "{"
+	for i, v := range b {
+		if i > 0 {
+			s += ", "
+		}
+		s += fmt.Sprintf("0x%02x", v)
+	}
+	return s + "}"
+}
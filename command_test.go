@@ -0,0 +1,73 @@
+package twine
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCommand(t *testing.T) {
+
+	b, _ := New(tests[1].key)
+	now := time.Unix(1700000000, 0)
+
+	const (
+		scopeValve = 1 << iota
+		scopeHeater
+		scopeFirmware
+	)
+
+	errBusy := errors.New("valve busy")
+	busy := false
+	v := NewCommandVerifier(b, scopeValve|scopeHeater, 10, func(c *Command) error {
+		if c.ID == 7 && busy {
+			return errBusy
+		}
+		return nil
+	})
+	v.now = func() time.Time { return now }
+
+	cmd := func(seq uint64, scope uint32, expires time.Time) []byte {
+		c := &Command{ID: 7, Scope: scope, Expires: expires, Seq: seq, Payload: []byte("open 30%")}
+		return c.Seal(b)
+	}
+	later := now.Add(time.Minute)
+
+	c, err := v.Verify(cmd(11, scopeValve, later))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.ID != 7 || c.Scope != scopeValve || c.Seq != 11 || !c.Expires.Equal(later) || string(c.Payload) != "open 30%" {
+		t.Errorf("Verify = %+v", c)
+	}
+
+	for _, tt := range []struct {
+		name string
+		env  []byte
+		want error
+	}{
+		{"replayed", cmd(11, scopeValve, later), ErrRollback},
+		{"old sequence", cmd(5, scopeValve, later), ErrRollback},
+		{"out of scope", cmd(12, scopeFirmware, later), ErrAuthFailed},
+		{"expired", cmd(12, scopeValve, now), ErrCommandExpired},
+		{"short", []byte{commandVersion, 0, 7}, ErrMalformedContainer},
+	} {
+		if _, err := v.Verify(tt.env); err != tt.want {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	env := cmd(12, scopeValve, later)
+	env[len(env)-9] ^= 1
+	if _, err := v.Verify(env); err != ErrAuthFailed {
+		t.Errorf("modified payload: err = %v", err)
+	}
+
+	busy = true
+	if _, err := v.Verify(cmd(12, scopeValve, later)); err != errBusy {
+		t.Errorf("policy hook: err = %v, want %v", err, errBusy)
+	}
+	if v.Seq() != 11 {
+		t.Errorf("rejected command advanced the sequence to %d", v.Seq())
+	}
+}
@@ -0,0 +1,135 @@
+package twine
+
+import (
+	"container/list"
+	"crypto/cipher"
+	"errors"
+	"sync"
+)
+
+// CompactKeys holds the keys of many devices in their raw 10 or 16 bytes
+// and keeps only a bounded number of them expanded, least recently used
+// first out.  An expanded cipher holds nearly 1.2KB of round keys and a raw
+// key with its slice header about 40 bytes, so a service holding millions
+// of device keys trades re-expansion on a miss for some 30 times less memory
+// per idle key.  A CompactKeys is safe for concurrent use.
+type CompactKeys struct {
+	mu       sync.Mutex
+	opts     []Option
+	max      int
+	keys     map[string][]byte
+	lru      *list.List
+	expanded map[string]*list.Element
+	hits     uint64
+	misses   uint64
+}
+
+type compactEntry struct {
+	id string
+	b  cipher.Block
+}
+
+// CompactStats reports the use of a CompactKeys' expanded ciphers.
+type CompactStats struct {
+	Keys     int    // raw keys held
+	Expanded int    // ciphers currently expanded
+	Hits     uint64 // Block calls served by an expanded cipher
+	Misses   uint64 // Block calls that expanded a key
+}
+
+// HitRate returns the fraction of Block calls served without expansion.
+func (s CompactStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+var errUnknownKey = errors.New("twine: unknown key id")
+
+// NewCompactKeys returns a CompactKeys keeping at most maxExpanded ciphers
+// expanded, each created with New(key, opts...).
+func NewCompactKeys(maxExpanded int, opts ...Option) *CompactKeys {
+	if maxExpanded < 1 {
+		panic("twine: CompactKeys must keep at least one cipher expanded")
+	}
+	return &CompactKeys{
+		opts:     opts,
+		max:      maxExpanded,
+		keys:     make(map[string][]byte),
+		lru:      list.New(),
+		expanded: make(map[string]*list.Element),
+	}
+}
+
+// Add stores a copy of key under id, replacing any previous key.
+func (c *CompactKeys) Add(id string, key []byte) error {
+	if len(key) != 10 && len(key) != 16 {
+		return KeySizeError(len(key))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.drop(id)
+	c.keys[id] = append([]byte(nil), key...)
+	return nil
+}
+
+// Remove wipes and drops the key stored under id.
+func (c *CompactKeys) Remove(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.drop(id)
+}
+
+// Block returns the cipher for id, expanding its key if it is not among the
+// recently used.  As with Cache.Get, evicted ciphers are not wiped, since
+// callers may still hold them.
+func (c *CompactKeys) Block(id string) (cipher.Block, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.expanded[id]; ok {
+		c.hits++
+		c.lru.MoveToFront(e)
+		return e.Value.(*compactEntry).b, nil
+	}
+
+	key, ok := c.keys[id]
+	if !ok {
+		return nil, errUnknownKey
+	}
+	c.misses++
+	b, err := New(key, c.opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	c.expanded[id] = c.lru.PushFront(&compactEntry{id: id, b: b})
+	for c.lru.Len() > c.max {
+		e := c.lru.Back()
+		c.lru.Remove(e)
+		delete(c.expanded, e.Value.(*compactEntry).id)
+	}
+
+	return b, nil
+}
+
+// Stats returns the current counts.
+func (c *CompactKeys) Stats() CompactStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CompactStats{Keys: len(c.keys), Expanded: c.lru.Len(), Hits: c.hits, Misses: c.misses}
+}
+
+func (c *CompactKeys) drop(id string) {
+	if key, ok := c.keys[id]; ok {
+		Wipe(key)
+		delete(c.keys, id)
+	}
+	if e, ok := c.expanded[id]; ok {
+		c.lru.Remove(e)
+		delete(c.expanded, id)
+	}
+}
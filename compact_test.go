@@ -0,0 +1,62 @@
+package twine
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCompactKeys(t *testing.T) {
+
+	c := NewCompactKeys(2)
+	for i, id := range []string{"a", "b", "c"} {
+		key := append([]byte(nil), tests[0].key...)
+		key[0] = byte(i)
+		if err := c.Add(id, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Add("d", make([]byte, 12)); err == nil {
+		t.Errorf("12-byte key accepted")
+	}
+
+	a, err := c.Block("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a2, _ := c.Block("a"); a2 != a {
+		t.Errorf("expanded cipher not reused")
+	}
+	c.Block("b")
+	c.Block("c") // evicts a
+	if a2, _ := c.Block("a"); a2 == a {
+		t.Errorf("least recently used cipher not evicted")
+	}
+
+	s := c.Stats()
+	if s != (CompactStats{Keys: 3, Expanded: 2, Hits: 1, Misses: 4}) {
+		t.Errorf("Stats = %+v", s)
+	}
+	if r := s.HitRate(); r != 0.2 {
+		t.Errorf("HitRate = %v, want 0.2", r)
+	}
+
+	// the expanded cipher matches one made from the key directly
+	want, _ := New(tests[0].key)
+	c.Add("spec", tests[0].key)
+	got, _ := c.Block("spec")
+	x, y := make([]byte, 8), make([]byte, 8)
+	want.Encrypt(x, tests[0].plain)
+	got.Encrypt(y, tests[0].plain)
+	if !bytes.Equal(x, y) {
+		t.Errorf("Encrypt = %x, want %x", y, x)
+	}
+
+	key := c.keys["spec"]
+	c.Remove("spec")
+	if _, err := c.Block("spec"); err == nil {
+		t.Errorf("removed key still usable")
+	}
+	if !bytes.Equal(key, make([]byte, len(key))) {
+		t.Errorf("removed key not wiped")
+	}
+}
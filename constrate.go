@@ -0,0 +1,62 @@
+package twine
+
+import (
+	"crypto/cipher"
+	"sync/atomic"
+	"time"
+)
+
+// ConstantRate is a cipher.Block that pads every Encrypt and Decrypt call of
+// an underlying block to a fixed duration, for hard-real-time systems that
+// need the same per-block timing whatever the backend or input.
+type ConstantRate struct {
+	b        cipher.Block
+	d        time.Duration
+	spin     bool
+	overruns atomic.Uint64
+}
+
+// NewConstantRate returns a ConstantRate wrapping b whose operations each
+// take at least d.  If spin is true the remaining time is busy-waited, which
+// is precise but occupies the CPU; otherwise the goroutine sleeps and the
+// precision is that of the runtime timer.
+func NewConstantRate(b cipher.Block, d time.Duration, spin bool) *ConstantRate {
+	return &ConstantRate{b: b, d: d, spin: spin}
+}
+
+func (c *ConstantRate) BlockSize() int { return c.b.BlockSize() }
+
+func (c *ConstantRate) Encrypt(dst, src []byte) {
+	start := time.Now()
+	c.b.Encrypt(dst, src)
+	c.wait(start)
+}
+
+func (c *ConstantRate) Decrypt(dst, src []byte) {
+	start := time.Now()
+	c.b.Decrypt(dst, src)
+	c.wait(start)
+}
+
+// Overruns returns the number of operations that did not finish within the
+// configured duration.
+func (c *ConstantRate) Overruns() uint64 {
+	return c.overruns.Load()
+}
+
+func (c *ConstantRate) wait(start time.Time) {
+	deadline := start.Add(c.d)
+
+	if !time.Now().Before(deadline) {
+		c.overruns.Add(1)
+		return
+	}
+
+	if !c.spin {
+		time.Sleep(time.Until(deadline))
+		return
+	}
+
+	for time.Now().Before(deadline) {
+	}
+}
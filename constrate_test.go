@@ -0,0 +1,39 @@
+package twine
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestConstantRate(t *testing.T) {
+
+	tst := tests[0]
+	b, _ := New(tst.key)
+
+	for _, spin := range []bool{true, false} {
+
+		const d = 2 * time.Millisecond
+		c := NewConstantRate(b, d, spin)
+
+		var ct, p [8]byte
+
+		start := time.Now()
+		c.Encrypt(ct[:], tst.plain)
+		c.Decrypt(p[:], ct[:])
+		if el := time.Since(start); el < 2*d {
+			t.Errorf("spin=%v: two operations took %v, want at least %v", spin, el, 2*d)
+		}
+
+		if !bytes.Equal(ct[:], tst.cipher) || !bytes.Equal(p[:], tst.plain) {
+			t.Errorf("spin=%v: wrapped cipher output differs", spin)
+		}
+	}
+
+	c := NewConstantRate(b, 0, true)
+	var ct [8]byte
+	c.Encrypt(ct[:], tst.plain)
+	if c.Overruns() != 1 {
+		t.Errorf("Overruns()=%d, want 1", c.Overruns())
+	}
+}
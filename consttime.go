@@ -0,0 +1,59 @@
+package twine
+
+// WithConstantTime makes the cipher evaluate the S-box as a boolean circuit
+// instead of indexing a table with secret data, in both the key schedule and
+// Encrypt/Decrypt, so that its memory access pattern is independent of the
+// key and data.  Use it where cache-timing attacks are in scope.  The
+// batched paths of EncryptBlocks and DecryptBlocks are table-free already.
+func WithConstantTime() Option {
+	return func(o *options) { o.constantTime = true }
+}
+
+// sboxCT evaluates the S-box on a single nybble without a table lookup
+func sboxCT(x byte) byte {
+	y0, y1, y2, y3 := sboxBits(uint64(x&1), uint64(x>>1&1), uint64(x>>2&1), uint64(x>>3&1))
+	return byte(y0&1 | (y1&1)<<1 | (y2&1)<<2 | (y3&1)<<3)
+}
+
+func sboxTable(x byte) byte { return sbox[x] }
+
+// keySbox returns the S-box used by the key schedule
+func (t *twineCipher) keySbox() func(byte) byte {
+	if t.constantTime {
+		return sboxCT
+	}
+	return sboxTable
+}
+
+// roundCT is roundF without table lookups.  The eight S-box inputs are
+// packed one per bit lane and substituted together by sboxBits.
+func roundCT(x uint64, rk *[8]byte) uint64 {
+	var b0, b1, b2, b3 uint64
+	for j := uint(0); j < 8; j++ {
+		v := byte(x>>(60-8*j)) ^ rk[j]
+		b0 |= uint64(v&1) << j
+		b1 |= uint64(v>>1&1) << j
+		b2 |= uint64(v>>2&1) << j
+		b3 |= uint64(v>>3&1) << j
+	}
+	y0, y1, y2, y3 := sboxBits(b0, b1, b2, b3)
+	var y uint64
+	for j := uint(0); j < 8; j++ {
+		y |= (y0>>j&1 | (y1>>j&1)<<1 | (y2>>j&1)<<2 | (y3>>j&1)<<3) << (56 - 8*j)
+	}
+	return x ^ y
+}
+
+func (t *twineCipher) encryptCT(x uint64) uint64 {
+	for i := 0; i < 35; i++ {
+		x = shuffle(roundCT(x, &t.rk[i]))
+	}
+	return roundCT(x, &t.rk[35])
+}
+
+func (t *twineCipher) decryptCT(x uint64) uint64 {
+	for i := 0; i < 35; i++ {
+		x = unshuffle(roundCT(x, &t.drk[i]))
+	}
+	return roundCT(x, &t.drk[35])
+}
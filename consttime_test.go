@@ -0,0 +1,48 @@
+package twine
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSboxCT(t *testing.T) {
+	for x := byte(0); x < 16; x++ {
+		if got := sboxCT(x); got != sbox[x] {
+			t.Errorf("sboxCT(%x) = %x, want %x", x, got, sbox[x])
+		}
+	}
+}
+
+func TestConstantTime(t *testing.T) {
+
+	for _, tt := range tests {
+		b, err := New(tt.key, WithConstantTime())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ref, _ := New(tt.key)
+		if b.(*twineCipher).rk != ref.(*twineCipher).rk {
+			t.Errorf("%d-bit key: constant-time key schedule differs", 8*len(tt.key))
+		}
+
+		var ct, pt [8]byte
+		b.Encrypt(ct[:], tt.plain)
+		if !bytes.Equal(ct[:], tt.cipher) {
+			t.Errorf("%d-bit key: Encrypt = %x, want %x", 8*len(tt.key), ct, tt.cipher)
+		}
+		b.Decrypt(pt[:], ct[:])
+		if !bytes.Equal(pt[:], tt.plain) {
+			t.Errorf("%d-bit key: Decrypt = %x, want %x", 8*len(tt.key), pt, tt.plain)
+		}
+	}
+}
+
+func BenchmarkEncryptConstantTime(b *testing.B) {
+	c, _ := New(tests[1].key, WithConstantTime())
+	var blk [8]byte
+	b.SetBytes(8)
+	for i := 0; i < b.N; i++ {
+		c.Encrypt(blk[:], blk[:])
+	}
+}
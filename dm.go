@@ -0,0 +1,119 @@
+package twine
+
+import (
+	"encoding/binary"
+	"hash"
+)
+
+// dmIV is the initial chaining value of the unkeyed hash (the first eight
+// bytes of the TWINE test vector plaintext)
+const dmIV = 0x0123456789abcdef
+
+const dmBlockSize = 16
+
+type dm struct {
+	h0  uint64
+	h   uint64
+	buf [dmBlockSize]byte
+	nx  int
+	len uint64
+}
+
+// NewDM returns a 64-bit hash.Hash64 built from TWINE-128 in the
+// Davies-Meyer construction: each 16-byte message block keys the cipher,
+// which encrypts the chaining value, and the result is xored with the chaining
+// value.  Messages are Merkle-Damgård strengthened.  This is suitable for
+// checksums and table hashing, not collision resistance: the output is only
+// 64 bits.
+func NewDM() hash.Hash64 {
+	d := &dm{h0: dmIV}
+	d.Reset()
+	return d
+}
+
+// NewKeyedDM returns a hash.Hash64 like NewDM whose initial chaining value is
+// derived from key, which should be 10 or 16 bytes.  It is intended for
+// hash-flooding resistant table hashing; it is not a MAC, as it is subject to
+// length extension.  Use a CMAC for authentication.
+func NewKeyedDM(key []byte) (hash.Hash64, error) {
+
+	b, err := New(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var iv [8]byte
+	binary.BigEndian.PutUint64(iv[:], dmIV)
+	b.Encrypt(iv[:], iv[:])
+
+	d := &dm{h0: binary.BigEndian.Uint64(iv[:])}
+	d.Reset()
+	return d, nil
+}
+
+func (d *dm) Reset() {
+	d.h = d.h0
+	d.nx = 0
+	d.len = 0
+}
+
+func (d *dm) Size() int { return 8 }
+
+func (d *dm) BlockSize() int { return dmBlockSize }
+
+func (d *dm) Write(p []byte) (int, error) {
+	n := len(p)
+	d.len += uint64(n)
+
+	if d.nx > 0 {
+		c := copy(d.buf[d.nx:], p)
+		d.nx += c
+		p = p[c:]
+		if d.nx < dmBlockSize {
+			return n, nil
+		}
+		d.block(d.buf[:])
+		d.nx = 0
+	}
+
+	for len(p) >= dmBlockSize {
+		d.block(p[:dmBlockSize])
+		p = p[dmBlockSize:]
+	}
+
+	d.nx = copy(d.buf[:], p)
+
+	return n, nil
+}
+
+func (d *dm) block(m []byte) {
+	var t twineCipher
+	t.expandKeys(m)
+
+	var x [8]byte
+	binary.BigEndian.PutUint64(x[:], d.h)
+	t.Encrypt(x[:], x[:])
+	d.h ^= binary.BigEndian.Uint64(x[:])
+}
+
+func (d *dm) Sum64() uint64 {
+	// work on a copy so the caller can keep writing
+	c := *d
+
+	var pad [2 * dmBlockSize]byte
+	pad[0] = 0x80
+	n := dmBlockSize - c.nx
+	if n < 9 {
+		n += dmBlockSize
+	}
+	binary.BigEndian.PutUint64(pad[n-8:], c.len<<3)
+	c.Write(pad[:n])
+
+	return c.h
+}
+
+func (d *dm) Sum(in []byte) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], d.Sum64())
+	return append(in, b[:]...)
+}
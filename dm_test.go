@@ -0,0 +1,66 @@
+package twine
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDM(t *testing.T) {
+
+	msg := make([]byte, 100)
+	for i := range msg {
+		msg[i] = byte(i)
+	}
+
+	keyed, err := NewKeyedDM(tests[0].key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, h := range []interface {
+		Write([]byte) (int, error)
+		Sum64() uint64
+		Sum([]byte) []byte
+		Reset()
+	}{NewDM(), keyed} {
+
+		seen := make(map[uint64]int)
+
+		for n := 0; n <= len(msg); n++ {
+			h.Reset()
+			h.Write(msg[:n])
+			want := h.Sum64()
+
+			if m, ok := seen[want]; ok {
+				t.Errorf("len %d and %d collide", m, n)
+			}
+			seen[want] = n
+
+			// byte-at-a-time writes must agree with a single write
+			h.Reset()
+			for i := 0; i < n; i++ {
+				h.Write(msg[i : i+1])
+			}
+			if got := h.Sum64(); got != want {
+				t.Errorf("len %d: incremental %016x, want %016x", n, got, want)
+			}
+
+			if got := h.Sum64(); got != want {
+				t.Errorf("len %d: Sum64 not idempotent", n)
+			}
+
+			sum := h.Sum([]byte{0xff})
+			if len(sum) != 9 || sum[0] != 0xff {
+				t.Errorf("len %d: Sum did not append", n)
+			}
+		}
+	}
+
+	d := NewDM()
+	d.Write(msg)
+	keyed.Reset()
+	keyed.Write(msg)
+	if bytes.Equal(d.Sum(nil), keyed.Sum(nil)) {
+		t.Error("keyed and unkeyed hashes agree")
+	}
+}
@@ -0,0 +1,137 @@
+package twine
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"errors"
+)
+
+// EAX nonce and default tag sizes
+const (
+	eaxNonceSize = 8
+	eaxTagSize   = 8
+)
+
+// eax implements the EAX mode of Bellare, Rogaway and Wagner over a 64-bit
+// block cipher, with CMAC as its OMAC
+type eax struct {
+	b       cipher.Block
+	mac     cmac // template, copied for each OMAC computation
+	tagSize int
+	budget  tagBudget // Open calls under the key
+	wiped   bool
+}
+
+// NewEAX returns b, which must have a 64-bit block, wrapped in EAX mode
+// with an 8-byte nonce and an 8-byte tag.  Nonces must never repeat under
+// one key: as with any mode over a 64-bit block, rekey well before 2^32
+// messages.  The AEAD also implements Wiper.
+func NewEAX(b cipher.Block) (cipher.AEAD, error) {
+	return NewEAXWithTagSize(b, eaxTagSize)
+}
+
+// NewEAXWithTagSize is like NewEAX but truncates tags to tagSize bytes.
+// Tags shorter than 8 bytes, down to 4, require AllowShortTags, and Open
+// returns ErrKeyExhausted once the key has opened as many messages as
+// AllowShortTags describes.
+func NewEAXWithTagSize(b cipher.Block, tagSize int, opts ...TagOption) (cipher.AEAD, error) {
+	if b.BlockSize() != 8 {
+		return nil, errors.New("twine: EAX requires a 64-bit block cipher")
+	}
+	if err := checkTagSize(tagSize, 8, opts); err != nil {
+		return nil, err
+	}
+	return &eax{b: b, mac: *NewCMAC(b).(*cmac), tagSize: tagSize, budget: tagBudget{limit: tagLimit(tagSize)}}, nil
+}
+
+// Wipe scrubs the CMAC subkeys.  The AEAD must not be used afterwards: Seal
+// and Open panic.
+func (e *eax) Wipe() {
+	e.mac.Wipe()
+	e.wiped = true
+}
+
+func (e *eax) NonceSize() int { return eaxNonceSize }
+
+func (e *eax) Overhead() int { return e.tagSize }
+
+// omac returns OMAC^t(data), the CMAC of the block [t] followed by data
+func (e *eax) omac(t byte, data []byte) [8]byte {
+	m := e.mac
+	m.Write([]byte{0, 0, 0, 0, 0, 0, 0, t})
+	m.Write(data)
+	var out [8]byte
+	m.Sum(out[:0])
+	return out
+}
+
+func (e *eax) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if e.wiped {
+		panic(errWipedMode)
+	}
+	if len(nonce) != eaxNonceSize {
+		panic("twine: incorrect nonce length given to EAX")
+	}
+
+	n := e.omac(0, nonce)
+	h := e.omac(1, additionalData)
+
+	ret, out := sliceForAppend(dst, len(plaintext)+e.tagSize)
+	ct := out[:len(plaintext)]
+	wrappingCTR(e.b, n[:]).XORKeyStream(ct, plaintext)
+
+	c := e.omac(2, ct)
+	for i := 0; i < e.tagSize; i++ {
+		out[len(plaintext)+i] = n[i] ^ h[i] ^ c[i]
+	}
+
+	return ret
+}
+
+func (e *eax) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if e.wiped {
+		panic(errWipedMode)
+	}
+	if len(nonce) != eaxNonceSize {
+		panic("twine: incorrect nonce length given to EAX")
+	}
+	if err := e.budget.spend(); err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < e.tagSize {
+		return nil, ErrAuthFailed
+	}
+
+	tag := ciphertext[len(ciphertext)-e.tagSize:]
+	ct := ciphertext[:len(ciphertext)-e.tagSize]
+
+	n := e.omac(0, nonce)
+	h := e.omac(1, additionalData)
+	c := e.omac(2, ct)
+
+	var want [8]byte
+	for i := range want {
+		want[i] = n[i] ^ h[i] ^ c[i]
+	}
+	if subtle.ConstantTimeCompare(want[:e.tagSize], tag) != 1 {
+		return nil, ErrAuthFailed
+	}
+
+	ret, out := sliceForAppend(dst, len(ct))
+	wrappingCTR(e.b, n[:]).XORKeyStream(out, ct)
+
+	return ret, nil
+}
+
+// sliceForAppend extends in by n bytes, reallocating if needed, and returns
+// the whole slice and the new tail
+func sliceForAppend(in []byte, n int) (head, tail []byte) {
+	if total := len(in) + n; cap(in) >= total {
+		head = in[:total]
+	} else {
+		head = make([]byte, total)
+		copy(head, in)
+	}
+	tail = head[len(in):]
+	return
+}
@@ -0,0 +1,127 @@
+package twine
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"testing"
+)
+
+// eaxRef computes EAX from its definition, using the package's CMAC as OMAC
+func eaxRef(b cipher.Block, nonce, pt, ad []byte) []byte {
+	omac := func(t byte, m []byte) []byte {
+		h := NewCMAC(b)
+		h.Write([]byte{0, 0, 0, 0, 0, 0, 0, t})
+		h.Write(m)
+		return h.Sum(nil)
+	}
+
+	n := omac(0, nonce)
+	h := omac(1, ad)
+	ct := make([]byte, len(pt))
+	cipher.NewCTR(b, n).XORKeyStream(ct, pt)
+	c := omac(2, ct)
+
+	tag := make([]byte, 8)
+	for i := range tag {
+		tag[i] = n[i] ^ h[i] ^ c[i]
+	}
+	return append(ct, tag...)
+}
+
+// testAEAD checks round trips, tag truncation against want and rejection of
+// modified messages for an AEAD
+func testAEAD(t *testing.T, name string, a cipher.AEAD, want func(nonce, pt, ad []byte) []byte) {
+
+	nonce := make([]byte, a.NonceSize())
+	for i := range nonce {
+		nonce[i] = byte(0xa0 + i)
+	}
+	msg := make([]byte, 70)
+	for i := range msg {
+		msg[i] = byte(i)
+	}
+	ad := []byte("header")
+
+	for _, n := range []int{0, 1, 7, 8, 9, 16, 70} {
+		for _, ad := range [][]byte{nil, ad} {
+			sealed := a.Seal([]byte("prefix"), nonce, msg[:n], ad)
+			if !bytes.HasPrefix(sealed, []byte("prefix")) {
+				t.Fatalf("%s: Seal did not append to dst", name)
+			}
+			sealed = sealed[len("prefix"):]
+			if len(sealed) != n+a.Overhead() {
+				t.Fatalf("%s: sealed length %d, want %d", name, len(sealed), n+a.Overhead())
+			}
+			if want != nil {
+				w := want(nonce, msg[:n], ad)
+				if !bytes.Equal(sealed, w[:len(sealed)]) {
+					t.Errorf("%s: %d bytes: Seal = %x, want %x", name, n, sealed, w[:len(sealed)])
+				}
+			}
+
+			pt, err := a.Open(nil, nonce, sealed, ad)
+			if err != nil || !bytes.Equal(pt, msg[:n]) {
+				t.Errorf("%s: %d bytes: Open = %x, %v", name, n, pt, err)
+			}
+
+			// in place
+			buf := append([]byte(nil), msg[:n]...)
+			buf = a.Seal(buf[:0], nonce, buf, ad)
+			if pt, err := a.Open(buf[:0], nonce, buf, ad); err != nil || !bytes.Equal(pt, msg[:n]) {
+				t.Errorf("%s: %d bytes in place: Open = %x, %v", name, n, pt, err)
+			}
+
+			for i := range sealed {
+				bad := append([]byte(nil), sealed...)
+				bad[i] ^= 0x10
+				if _, err := a.Open(nil, nonce, bad, ad); err != ErrAuthFailed {
+					t.Errorf("%s: %d bytes: flipped byte %d: err = %v", name, n, i, err)
+				}
+			}
+			if _, err := a.Open(nil, nonce, sealed, []byte("other")); err != ErrAuthFailed {
+				t.Errorf("%s: %d bytes: wrong AD accepted", name, n)
+			}
+			if len(nonce) > 0 {
+				other := append([]byte(nil), nonce...)
+				other[0] ^= 1
+				if _, err := a.Open(nil, other, sealed, ad); err != ErrAuthFailed {
+					t.Errorf("%s: %d bytes: wrong nonce accepted", name, n)
+				}
+			}
+		}
+	}
+
+	if _, err := a.Open(nil, nonce, make([]byte, a.Overhead()-1), nil); err != ErrAuthFailed {
+		t.Errorf("%s: short ciphertext: err = %v", name, err)
+	}
+}
+
+func TestEAX(t *testing.T) {
+
+	b, _ := New(tests[1].key)
+	ref := func(nonce, pt, ad []byte) []byte { return eaxRef(b, nonce, pt, ad) }
+
+	a, err := NewEAX(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testAEAD(t, "EAX", a, ref)
+
+	a, _ = NewEAXWithTagSize(b, 4, AllowShortTags())
+	testAEAD(t, "EAX-32", a, func(nonce, pt, ad []byte) []byte {
+		w := ref(nonce, pt, ad)
+		return w[:len(w)-4]
+	})
+
+	if _, err := NewEAXWithTagSize(b, 4); err == nil {
+		t.Errorf("4-byte tag accepted without AllowShortTags")
+	}
+	if _, err := NewEAXWithTagSize(b, 3, AllowShortTags()); err == nil {
+		t.Errorf("3-byte tag accepted")
+	}
+
+	w, _ := New(tests[1].key)
+	a, _ = NewEAX(w)
+	a.(Wiper).Wipe()
+	mustPanic(t, "EAX Seal after Wipe", func() { a.Seal(nil, make([]byte, 8), nil, nil) })
+}
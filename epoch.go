@@ -0,0 +1,61 @@
+package twine
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+// EpochKeys derives a key for each time slot of a one-to-many broadcast
+// channel from a master key, so that senders and receivers sharing the
+// master agree on the current key without any exchange, and a slot key that
+// leaks exposes only its slot.
+//
+// Slot keys are 16 bytes, derived with CMAC under the master as the PRF of
+// the NIST SP 800-108 counter-mode KDF, with the label "twine epoch" and the
+// big-endian slot number as context.  An EpochKeys is safe for concurrent
+// use.
+type EpochKeys struct {
+	master cipher.Block
+	start  time.Time
+	slot   time.Duration
+}
+
+const epochLabel = "twine epoch"
+
+// NewEpochKeys returns an EpochKeys whose slot 0 begins at start and whose
+// slots last for slot.
+func NewEpochKeys(master cipher.Block, start time.Time, slot time.Duration) *EpochKeys {
+	if slot <= 0 {
+		panic("twine: epoch slot must be positive")
+	}
+	return &EpochKeys{master: master, start: start, slot: slot}
+}
+
+// Epoch returns the slot containing t.  Times before the start of slot 0
+// are an error.
+func (e *EpochKeys) Epoch(t time.Time) (uint64, error) {
+	if t.Before(e.start) {
+		return 0, errors.New("twine: time precedes the first epoch")
+	}
+	return uint64(t.Sub(e.start) / e.slot), nil
+}
+
+// Key returns the 16-byte key of slot epoch.
+func (e *EpochKeys) Key(epoch uint64) []byte {
+	var ctx [8]byte
+	binary.BigEndian.PutUint64(ctx[:], epoch)
+	return deriveKey(e.master, epochLabel, ctx[:])
+}
+
+// Block returns a cipher keyed with the key of the slot containing t.
+func (e *EpochKeys) Block(t time.Time) (cipher.Block, error) {
+	epoch, err := e.Epoch(t)
+	if err != nil {
+		return nil, err
+	}
+	key := e.Key(epoch)
+	defer Wipe(key)
+	return New(key)
+}
@@ -0,0 +1,59 @@
+package twine
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestEpochKeys(t *testing.T) {
+
+	master, _ := New(tests[1].key)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	e := NewEpochKeys(master, start, time.Minute)
+
+	for _, tt := range []struct {
+		t     time.Time
+		epoch uint64
+	}{
+		{start, 0},
+		{start.Add(59 * time.Second), 0},
+		{start.Add(time.Minute), 1},
+		{start.Add(24 * time.Hour), 1440},
+	} {
+		if got, err := e.Epoch(tt.t); err != nil || got != tt.epoch {
+			t.Errorf("Epoch(%v) = %d, %v, want %d", tt.t, got, err, tt.epoch)
+		}
+	}
+	if _, err := e.Epoch(start.Add(-time.Second)); err == nil {
+		t.Errorf("Epoch before start succeeded")
+	}
+
+	seen := make(map[string]bool)
+	for i := uint64(0); i < 100; i++ {
+		k := e.Key(i)
+		if len(k) != 16 {
+			t.Fatalf("key length %d", len(k))
+		}
+		if seen[string(k)] {
+			t.Errorf("epoch %d repeats a key", i)
+		}
+		seen[string(k)] = true
+	}
+
+	// a receiver with the same master derives the same keys
+	r := NewEpochKeys(master, start, time.Minute)
+	if !bytes.Equal(r.Key(7), e.Key(7)) {
+		t.Errorf("keys not deterministic")
+	}
+
+	// and so do ciphers for times in the same slot
+	b1, _ := e.Block(start.Add(90 * time.Second))
+	b2, _ := r.Block(start.Add(61 * time.Second))
+	var c1, c2 [8]byte
+	b1.Encrypt(c1[:], c1[:])
+	b2.Encrypt(c2[:], c2[:])
+	if c1 != c2 {
+		t.Errorf("ciphers for the same slot differ")
+	}
+}
@@ -0,0 +1,18 @@
+package twine
+
+import "errors"
+
+// Errors shared by the modes and formats in this package.  ErrAuthFailed,
+// ErrNonceReused and ErrMalformedContainer may indicate an attack on the
+// data or protocol; ErrCounterOverflow and ErrKeyExhausted mean the caller
+// has used a key or nonce beyond its limits and must rekey.  ErrRollback
+// means authentic data is older than the receiver accepts, as when an old
+// image or configuration is replayed.
+var (
+	ErrAuthFailed         = errors.New("twine: message authentication failed")
+	ErrNonceReused        = errors.New("twine: nonce reused")
+	ErrCounterOverflow    = errors.New("twine: counter overflow")
+	ErrKeyExhausted       = errors.New("twine: key usage limit reached")
+	ErrMalformedContainer = errors.New("twine: malformed container")
+	ErrRollback           = errors.New("twine: rollback counter too old")
+)
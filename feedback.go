@@ -0,0 +1,91 @@
+package twine
+
+import "crypto/cipher"
+
+// Feedback updates the shift register reg in place after each segment, given
+// the full block cipher output out and the ciphertext segment ct.
+type Feedback func(reg, out, ct []byte)
+
+// FeedbackOutput replaces the register with the cipher output (OFB).
+func FeedbackOutput(reg, out, ct []byte) { copy(reg, out) }
+
+// FeedbackCiphertext shifts the ciphertext segment into the register from
+// the right (CFB; with a one-byte width this is CFB-8).
+func FeedbackCiphertext(reg, out, ct []byte) {
+	n := copy(reg, reg[len(ct):])
+	copy(reg[n:], ct)
+}
+
+// FeedbackCounter increments the register as a big-endian integer (CTR).
+func FeedbackCounter(reg, out, ct []byte) {
+	for i := len(reg) - 1; i >= 0; i-- {
+		reg[i]++
+		if reg[i] != 0 {
+			return
+		}
+	}
+}
+
+type feedbackStream struct {
+	b       cipher.Block
+	reg     []byte
+	out     []byte
+	seg     []byte
+	pos     int
+	fb      Feedback
+	decrypt bool
+}
+
+// NewFeedbackStream returns a cipher.Stream for a generic feedback mode.  The
+// shift register starts as iv; for each segment of width bytes the register
+// is encrypted, the first width bytes of the output are xored with the data,
+// and fb computes the next register.  Since feedback may depend on the
+// ciphertext, encryption and decryption need separate streams.  The width
+// must be between 1 and the block size.
+func NewFeedbackStream(b cipher.Block, iv []byte, width int, fb Feedback, decrypt bool) cipher.Stream {
+
+	bs := b.BlockSize()
+	if len(iv) != bs {
+		panic("twine: IV length must equal block size")
+	}
+	if width < 1 || width > bs {
+		panic("twine: invalid feedback width")
+	}
+
+	return &feedbackStream{
+		b:       b,
+		reg:     append([]byte(nil), iv...),
+		out:     make([]byte, bs),
+		seg:     make([]byte, width),
+		fb:      fb,
+		decrypt: decrypt,
+	}
+}
+
+func (f *feedbackStream) XORKeyStream(dst, src []byte) {
+
+	if len(dst) < len(src) {
+		panic("twine: output smaller than input")
+	}
+
+	for i, s := range src {
+		if f.pos == 0 {
+			f.b.Encrypt(f.out, f.reg)
+		}
+
+		d := s ^ f.out[f.pos]
+		dst[i] = d
+
+		if f.decrypt {
+			f.seg[f.pos] = s
+		} else {
+			f.seg[f.pos] = d
+		}
+
+		f.pos++
+		if f.pos == len(f.seg) {
+			f.fb(f.reg, f.out, f.seg)
+			f.pos = 0
+		}
+	}
+}
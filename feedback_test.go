@@ -0,0 +1,63 @@
+package twine
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"testing"
+)
+
+func TestFeedbackStream(t *testing.T) {
+
+	b, _ := New(tests[1].key)
+	iv := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+
+	msg := make([]byte, 77)
+	for i := range msg {
+		msg[i] = byte(i)
+	}
+
+	// the standard modes expressed as feedback functions
+	std := []struct {
+		name string
+		fb   Feedback
+		enc  cipher.Stream
+		dec  cipher.Stream
+	}{
+		{"OFB", FeedbackOutput, cipher.NewOFB(b, iv), cipher.NewOFB(b, iv)},
+		{"CFB", FeedbackCiphertext, cipher.NewCFBEncrypter(b, iv), cipher.NewCFBDecrypter(b, iv)},
+		{"CTR", FeedbackCounter, cipher.NewCTR(b, iv), cipher.NewCTR(b, iv)},
+	}
+
+	for _, s := range std {
+		want := make([]byte, len(msg))
+		s.enc.XORKeyStream(want, msg)
+
+		got := make([]byte, len(msg))
+		enc := NewFeedbackStream(b, iv, 8, s.fb, false)
+		// uneven writes must not change the output
+		enc.XORKeyStream(got[:3], msg[:3])
+		enc.XORKeyStream(got[3:20], msg[3:20])
+		enc.XORKeyStream(got[20:], msg[20:])
+
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: got % 02x\nwant % 02x", s.name, got, want)
+		}
+
+		NewFeedbackStream(b, iv, 8, s.fb, true).XORKeyStream(got, got)
+		if !bytes.Equal(got, msg) {
+			t.Errorf("%s: decrypt failed", s.name)
+		}
+	}
+
+	// CFB-8 and CFB-24 round trips
+	for _, w := range []int{1, 3} {
+		ct := make([]byte, len(msg))
+		NewFeedbackStream(b, iv, w, FeedbackCiphertext, false).XORKeyStream(ct, msg)
+
+		pt := make([]byte, len(msg))
+		NewFeedbackStream(b, iv, w, FeedbackCiphertext, true).XORKeyStream(pt, ct)
+		if !bytes.Equal(pt, msg) {
+			t.Errorf("CFB-%d: round trip failed", 8*w)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+//go:build amd64 && !purego
+
+package gf64
+
+var hasPCLMUL = cpuidPCLMUL()
+
+//go:noescape
+func clmulAsm(a, b uint64) (hi, lo uint64)
+
+func cpuidPCLMUL() bool
+
+func clmul(a, b uint64) (hi, lo uint64) {
+	if hasPCLMUL {
+		return clmulAsm(a, b)
+	}
+	return clmulGeneric(a, b)
+}
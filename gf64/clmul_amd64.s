@@ -0,0 +1,23 @@
+//go:build amd64 && !purego
+
+#include "textflag.h"
+
+// func clmulAsm(a, b uint64) (hi, lo uint64)
+TEXT ·clmulAsm(SB), NOSPLIT, $0-32
+	MOVQ      a+0(FP), X0
+	MOVQ      b+8(FP), X1
+	PCLMULQDQ $0x00, X1, X0
+	MOVQ      X0, lo+24(FP)
+	PSRLDQ    $8, X0
+	MOVQ      X0, hi+16(FP)
+	RET
+
+// func cpuidPCLMUL() bool
+TEXT ·cpuidPCLMUL(SB), NOSPLIT, $0-1
+	MOVL $1, AX
+	XORL CX, CX
+	CPUID
+	SHRL $1, CX
+	ANDL $1, CX
+	MOVB CX, ret+0(FP)
+	RET
@@ -0,0 +1,7 @@
+//go:build !amd64 || purego
+
+package gf64
+
+func clmul(a, b uint64) (hi, lo uint64) {
+	return clmulGeneric(a, b)
+}
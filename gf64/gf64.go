@@ -0,0 +1,53 @@
+// Package gf64 implements arithmetic in GF(2^64) as used by 64-bit block
+// cipher modes (CMAC and PMAC subkeys, XEX/XTS and LRW tweak masks).
+//
+// Field elements are uint64s in which bit i is the coefficient of x^i; the
+// field is defined by the polynomial x^64 + x^4 + x^3 + x + 1.  A byte
+// string is mapped to an element by reading it as a big-endian integer, the
+// convention of the 64-bit CMAC and XTS specifications.
+//
+// Multiplication uses PCLMULQDQ on amd64 when available; elsewhere it falls
+// back to a constant-time pure-Go implementation.
+package gf64
+
+// Poly is the low part of the reduction polynomial x^64 + x^4 + x^3 + x + 1.
+const Poly = 0x1b
+
+// ClMul returns the 128-bit carry-less product of a and b.
+func ClMul(a, b uint64) (hi, lo uint64) {
+	return clmul(a, b)
+}
+
+// Reduce returns hi*x^64 + lo reduced modulo the field polynomial.
+func Reduce(hi, lo uint64) uint64 {
+	// hi*x^64 = hi*(x^4 + x^3 + x + 1); the bits shifted out of the
+	// word are at most x^3 and are folded in once more
+	over := hi>>63 ^ hi>>61 ^ hi>>60
+	lo ^= hi ^ hi<<1 ^ hi<<3 ^ hi<<4
+	return lo ^ over ^ over<<1 ^ over<<3 ^ over<<4
+}
+
+// Mul returns the product of a and b in GF(2^64).
+func Mul(a, b uint64) uint64 {
+	return Reduce(clmul(a, b))
+}
+
+// Double returns a*x in GF(2^64), in constant time.
+func Double(a uint64) uint64 {
+	return a<<1 ^ -(a>>63)&Poly
+}
+
+// Half returns a*x^-1 in GF(2^64), the inverse of Double, in constant time.
+func Half(a uint64) uint64 {
+	// a*x^-1 = (a + (a&1)*P(x)) / x
+	return a>>1 ^ -(a&1)&(1<<63|Poly>>1)
+}
+
+func clmulGeneric(a, b uint64) (hi, lo uint64) {
+	for i := uint(0); i < 64; i++ {
+		mask := -(b >> i & 1)
+		lo ^= a << i & mask
+		hi ^= a >> (64 - i) & mask
+	}
+	return hi, lo
+}
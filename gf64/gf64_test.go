@@ -0,0 +1,69 @@
+package gf64
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestDouble(t *testing.T) {
+
+	if got := Double(1 << 63); got != Poly {
+		t.Errorf("Double(x^63)=%x, want %x", got, Poly)
+	}
+
+	for i := 0; i < 1000; i++ {
+		a := rand.Uint64()
+		if Mul(a, 2) != Double(a) {
+			t.Fatalf("Mul(%x, x) != Double", a)
+		}
+		if Half(Double(a)) != a || Double(Half(a)) != a {
+			t.Fatalf("Half is not the inverse of Double for %x", a)
+		}
+	}
+}
+
+func TestClMul(t *testing.T) {
+
+	for i := 0; i < 1000; i++ {
+		a, b := rand.Uint64(), rand.Uint64()
+		hi, lo := ClMul(a, b)
+		ghi, glo := clmulGeneric(a, b)
+		if hi != ghi || lo != glo {
+			t.Fatalf("ClMul(%x, %x)=%x:%x, generic %x:%x", a, b, hi, lo, ghi, glo)
+		}
+	}
+
+	if hi, lo := ClMul(1<<63, 1<<63); hi != 1<<62 || lo != 0 {
+		t.Errorf("ClMul(x^63, x^63)=%x:%x, want x^126", hi, lo)
+	}
+}
+
+func TestMul(t *testing.T) {
+
+	for i := 0; i < 1000; i++ {
+		a, b, c := rand.Uint64(), rand.Uint64(), rand.Uint64()
+
+		if Mul(a, 1) != a {
+			t.Fatalf("Mul(%x, 1) != %x", a, a)
+		}
+		if Mul(a, b) != Mul(b, a) {
+			t.Fatalf("Mul not commutative for %x, %x", a, b)
+		}
+		if Mul(a, b^c) != Mul(a, b)^Mul(a, c) {
+			t.Fatalf("Mul not distributive for %x, %x, %x", a, b, c)
+		}
+		if Mul(Mul(a, b), c) != Mul(a, Mul(b, c)) {
+			t.Fatalf("Mul not associative for %x, %x, %x", a, b, c)
+		}
+	}
+
+	// the reduction must agree with repeated doubling
+	a := rand.Uint64()
+	want := a
+	for i := 0; i < 64; i++ {
+		want = Double(want)
+	}
+	if got := Reduce(a, 0); got != want {
+		t.Errorf("Reduce(a*x^64)=%x, want %x", got, want)
+	}
+}
@@ -0,0 +1,196 @@
+package twine
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// A sealed firmware image is a header, a table of chunk tags, a tag over
+// both, and the image data:
+//
+//	magic "TWFW" | format 1 (4) | version (4) | rollback (4) |
+//	chunk size (4) | length (8) | reserved (4)
+//	tag of chunk 0 | ... | tag of chunk n-1
+//	header tag
+//	data
+//
+// Integers are big-endian.  Chunk i's tag is the CMAC of i (8 bytes) and the
+// chunk; the header tag is the CMAC of the header and tag table.
+//
+// VerifyImage streams: it runs the tag table through the header tag without
+// storing it, then recomputes each chunk's tag as the data passes through,
+// and accepts the image only if the recomputed tags give the same header
+// tag.  Its state is a few hundred bytes whatever the image's size, which
+// suits bootloaders that have room only for TWINE.
+
+const (
+	imageMagic      = "TWFW"
+	imageFormat     = 1
+	imageHeaderSize = 32
+)
+
+// ImageHeader is the metadata of a sealed firmware image.
+type ImageHeader struct {
+	Version   uint32 // firmware version, for information
+	Rollback  uint32 // anti-rollback counter
+	ChunkSize uint32
+	Length    uint64 // length of the image data
+}
+
+func (h *ImageHeader) chunks() uint64 {
+	n := h.Length / uint64(h.ChunkSize)
+	if h.Length%uint64(h.ChunkSize) != 0 {
+		n++
+	}
+	return n
+}
+
+func (h *ImageHeader) marshal() []byte {
+	b := make([]byte, imageHeaderSize)
+	copy(b, imageMagic)
+	binary.BigEndian.PutUint32(b[4:], imageFormat)
+	binary.BigEndian.PutUint32(b[8:], h.Version)
+	binary.BigEndian.PutUint32(b[12:], h.Rollback)
+	binary.BigEndian.PutUint32(b[16:], h.ChunkSize)
+	binary.BigEndian.PutUint64(b[20:], h.Length)
+	return b
+}
+
+func chunkTag(mac cipher.Block, i uint64, chunk []byte) []byte {
+	m := NewCMAC(mac)
+	var idx [8]byte
+	binary.BigEndian.PutUint64(idx[:], i)
+	m.Write(idx[:])
+	m.Write(chunk)
+	return m.Sum(nil)
+}
+
+// SealImage writes data to w as a sealed image with header h, whose Length
+// is set from data.  mac must have a 64-bit block.
+func SealImage(w io.Writer, mac cipher.Block, h ImageHeader, data []byte) error {
+
+	if h.ChunkSize == 0 {
+		return errors.New("twine: image chunk size must be positive")
+	}
+	h.Length = uint64(len(data))
+
+	hdr := NewCMAC(mac)
+	buf := h.marshal()
+	hdr.Write(buf)
+
+	for i := uint64(0); i < h.chunks(); i++ {
+		start := i * uint64(h.ChunkSize)
+		end := start + uint64(h.ChunkSize)
+		if end > h.Length {
+			end = h.Length
+		}
+		tag := chunkTag(mac, i, data[start:end])
+		hdr.Write(tag)
+		buf = append(buf, tag...)
+	}
+	buf = hdr.Sum(buf)
+
+	if _, err := w.Write(buf); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// VerifyImage reads a sealed image from r, checks its header and that its
+// rollback counter is at least minRollback, and copies the data to w.  The
+// header is authenticated before any data is written, but the data is only
+// known to be authentic once VerifyImage returns nil: on error, w may have
+// received some or all of it, so a bootloader should write to a staging
+// slot and only mark it bootable on success.
+//
+// It returns ErrMalformedContainer for an image it cannot parse,
+// ErrAuthFailed if the image was modified, and ErrRollback if it is
+// authentic but too old.
+func VerifyImage(w io.Writer, r io.Reader, mac cipher.Block, minRollback uint32) (ImageHeader, error) {
+
+	var h ImageHeader
+	var buf [256]byte
+
+	hb := buf[:imageHeaderSize]
+	if _, err := io.ReadFull(r, hb); err != nil {
+		return h, ErrMalformedContainer
+	}
+	if !bytes.Equal(hb[:4], []byte(imageMagic)) || binary.BigEndian.Uint32(hb[4:]) != imageFormat {
+		return h, ErrMalformedContainer
+	}
+	h.Version = binary.BigEndian.Uint32(hb[8:])
+	h.Rollback = binary.BigEndian.Uint32(hb[12:])
+	h.ChunkSize = binary.BigEndian.Uint32(hb[16:])
+	h.Length = binary.BigEndian.Uint64(hb[20:])
+	if h.ChunkSize == 0 {
+		return h, ErrMalformedContainer
+	}
+
+	// hdr authenticates the stored tag table, check the recomputed one
+	hdr, check := NewCMAC(mac), NewCMAC(mac)
+	hdr.Write(hb)
+	check.Write(hb)
+
+	n := h.chunks()
+	for left := n; left > 0; {
+		k := uint64(len(buf) / 8)
+		if left < k {
+			k = left
+		}
+		if _, err := io.ReadFull(r, buf[:8*k]); err != nil {
+			return h, ErrMalformedContainer
+		}
+		hdr.Write(buf[:8*k])
+		left -= k
+	}
+
+	var tag [8]byte
+	if _, err := io.ReadFull(r, tag[:]); err != nil {
+		return h, ErrMalformedContainer
+	}
+	if subtle.ConstantTimeCompare(hdr.Sum(nil), tag[:]) != 1 {
+		return h, ErrAuthFailed
+	}
+	if h.Rollback < minRollback {
+		return h, ErrRollback
+	}
+
+	left := h.Length
+	for i := uint64(0); i < n; i++ {
+		m := NewCMAC(mac)
+		binary.BigEndian.PutUint64(buf[:8], i)
+		m.Write(buf[:8])
+
+		c := uint64(h.ChunkSize)
+		if left < c {
+			c = left
+		}
+		left -= c
+		for c > 0 {
+			p := buf[:]
+			if c < uint64(len(p)) {
+				p = p[:c]
+			}
+			if _, err := io.ReadFull(r, p); err != nil {
+				return h, ErrMalformedContainer
+			}
+			m.Write(p)
+			if _, err := w.Write(p); err != nil {
+				return h, err
+			}
+			c -= uint64(len(p))
+		}
+		check.Write(m.Sum(nil))
+	}
+
+	if subtle.ConstantTimeCompare(check.Sum(nil), tag[:]) != 1 {
+		return h, ErrAuthFailed
+	}
+
+	return h, nil
+}
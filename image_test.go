@@ -0,0 +1,74 @@
+package twine
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestImage(t *testing.T) {
+
+	mac, _ := New(tests[1].key)
+
+	data := make([]byte, 1000)
+	for i := range data {
+		data[i] = byte(i * 13)
+	}
+
+	for _, n := range []int{0, 1, 256, 1000} {
+		var img bytes.Buffer
+		if err := SealImage(&img, mac, ImageHeader{Version: 3, Rollback: 7, ChunkSize: 256}, data[:n]); err != nil {
+			t.Fatal(err)
+		}
+
+		var out bytes.Buffer
+		h, err := VerifyImage(&out, bytes.NewReader(img.Bytes()), mac, 7)
+		if err != nil {
+			t.Fatalf("%d bytes: %v", n, err)
+		}
+		if h.Version != 3 || h.Rollback != 7 || h.Length != uint64(n) {
+			t.Errorf("%d bytes: header %+v", n, h)
+		}
+		if !bytes.Equal(out.Bytes(), data[:n]) {
+			t.Errorf("%d bytes: data differs", n)
+		}
+
+		if _, err := VerifyImage(io.Discard, bytes.NewReader(img.Bytes()), mac, 8); err != ErrRollback {
+			t.Errorf("%d bytes: old image: err = %v", n, err)
+		}
+
+		for i := 4; i < img.Len(); i += 7 {
+			bad := append([]byte(nil), img.Bytes()...)
+			bad[i] ^= 1
+			if _, err := VerifyImage(io.Discard, bytes.NewReader(bad), mac, 0); err == nil {
+				t.Errorf("%d bytes: flipped byte %d accepted", n, i)
+			}
+		}
+
+		if n > 0 {
+			if _, err := VerifyImage(io.Discard, bytes.NewReader(img.Bytes()[:img.Len()-1]), mac, 0); err != ErrMalformedContainer {
+				t.Errorf("%d bytes: truncated image: err = %v", n, err)
+			}
+		}
+	}
+
+	// an unauthenticated header cannot make the verifier allocate or
+	// overflow, however large the sizes it claims
+	for _, h := range []ImageHeader{
+		{ChunkSize: ^uint32(0), Length: ^uint64(0)},
+		{ChunkSize: 1, Length: ^uint64(0)},
+	} {
+		if n := h.chunks(); n == 0 {
+			t.Errorf("%+v: chunks overflowed", h)
+		}
+		hdr := h.marshal()
+		allocs := testing.AllocsPerRun(10, func() {
+			if _, err := VerifyImage(io.Discard, bytes.NewReader(hdr), mac, 0); err != ErrMalformedContainer {
+				t.Errorf("%+v: err = %v", h, err)
+			}
+		})
+		if allocs > 10 {
+			t.Errorf("%+v: %v allocations", h, allocs)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package twine
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+)
+
+// deriveKey returns a 16-byte key derived from master with the NIST SP
+// 800-108 counter-mode KDF, using CMAC under master as the PRF.  The label
+// separates uses of the same master; context identifies the key within a
+// use.
+func deriveKey(master cipher.Block, label string, context []byte) []byte {
+
+	prf := NewCMAC(master)
+	key := make([]byte, 0, 16)
+
+	for i := byte(1); i <= 2; i++ {
+		prf.Reset()
+		// [i] || label || 0x00 || context || [L], L = 128 bits
+		prf.Write([]byte{i})
+		prf.Write([]byte(label))
+		prf.Write([]byte{0})
+		prf.Write(context)
+		var l [4]byte
+		binary.BigEndian.PutUint32(l[:], 128)
+		prf.Write(l[:])
+		key = prf.Sum(key)
+	}
+
+	return key
+}
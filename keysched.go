@@ -0,0 +1,99 @@
+package twine
+
+// keyState is the key schedule register WK: 20 nybbles for an
+// 80-bit key, 32 for a 128-bit key.  step advances it by one round; unstep
+// is its inverse, so the schedule can also be run backwards from the final
+// state.
+type keyState struct {
+	wk [32]byte // actually nybbles
+	n  int
+}
+
+// the nybbles of WK forming each round key
+var (
+	keyTaps80  = [8]int{1, 3, 4, 6, 13, 14, 15, 16}
+	keyTaps128 = [8]int{2, 3, 12, 15, 17, 18, 28, 31}
+)
+
+func (k *keyState) init(key []byte) {
+	k.n = 2 * len(key)
+	for i := 0; i < len(key); i++ {
+		k.wk[2*i] = key[i] >> 4
+		k.wk[2*i+1] = key[i] & 0x0f
+	}
+}
+
+// roundKey extracts the current round key into rk
+func (k *keyState) roundKey(rk *[8]byte) {
+	taps := &keyTaps80
+	if k.n == 32 {
+		taps = &keyTaps128
+	}
+	for j, tap := range taps {
+		rk[j] = k.wk[tap]
+	}
+}
+
+// step advances the register past round i, 0 <= i < 35
+func (k *keyState) step(i int, sb func(byte) byte) {
+	wk := &k.wk
+	n := k.n
+
+	wk[1] ^= sb(wk[0])
+	wk[4] ^= sb(wk[16])
+	if n == 32 {
+		wk[23] ^= sb(wk[30])
+	}
+	con := roundconst[i]
+	wk[7] ^= con >> 3
+	wk[19] ^= con & 7
+
+	tmp0, tmp1, tmp2, tmp3 := wk[0], wk[1], wk[2], wk[3]
+	copy(wk[:n-4], wk[4:n])
+	wk[n-4] = tmp1
+	wk[n-3] = tmp2
+	wk[n-2] = tmp3
+	wk[n-1] = tmp0
+}
+
+// unstep undoes step(i, sb)
+func (k *keyState) unstep(i int, sb func(byte) byte) {
+	wk := &k.wk
+	n := k.n
+
+	tmp1, tmp2, tmp3, tmp0 := wk[n-4], wk[n-3], wk[n-2], wk[n-1]
+	copy(wk[4:n], wk[:n-4])
+	wk[0], wk[1], wk[2], wk[3] = tmp0, tmp1, tmp2, tmp3
+
+	con := roundconst[i]
+	wk[7] ^= con >> 3
+	wk[19] ^= con & 7
+	if n == 32 {
+		wk[23] ^= sb(wk[30])
+	}
+	wk[4] ^= sb(wk[16])
+	wk[1] ^= sb(wk[0])
+}
+
+func (k *keyState) wipe() {
+	Wipe(k.wk[:])
+}
+
+func (t *twineCipher) expandKeys(key []byte) {
+
+	var ks keyState
+	defer ks.wipe()
+
+	sb := t.keySbox()
+
+	ks.init(key)
+	for i := 0; i < 35; i++ {
+		ks.roundKey(&t.rk[i])
+		ks.step(i, sb)
+	}
+	ks.roundKey(&t.rk[35])
+
+	for i := range t.rk {
+		t.drk[i] = t.rk[35-i]
+	}
+}
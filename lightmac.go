@@ -0,0 +1,152 @@
+package twine
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"hash"
+)
+
+// lightmac implements LightMAC (Luykx, Preneel, Tischhauser and Yasuda,
+// FSE 2016) for a 64-bit block cipher
+type lightmac struct {
+	b1, b2 cipher.Block
+	mb     MultiBlock // b1, if it can encrypt in batches
+	s      int        // counter size in bytes
+	max    uint64     // largest counter value
+	ctr    uint64
+
+	v uint64
+
+	q  [8 * pmacBatch]byte // counter||block inputs awaiting encryption
+	nq int
+
+	buf [8]byte // last, possibly full, message block
+	nx  int
+
+	overflow bool // more was written than the counter can cover
+	wiped    bool
+}
+
+// NewLightMAC returns a hash.Hash computing LightMAC with an s-byte counter,
+// 1 <= s <= 7.  Each cipher call under b1 takes an s-byte block counter and
+// 8-s message bytes, and the sum of their outputs, xored with the padded last
+// block, is encrypted under b2.  b1 and b2 must have 64-bit blocks and be
+// keyed independently.
+//
+// Messages may be at most 2^(8s)*(8-s) bytes; s = 2 allows messages of
+// about 393KB.  As hash.Hash requires, Write never fails, but Sum panics with
+// ErrCounterOverflow if more was written, so a tag is never computed over
+// part of the data.  The per-block calls are independent, and are made in
+// batches through EncryptBlocks when b1 implements MultiBlock.  The returned
+// hash also implements Wiper.
+func NewLightMAC(b1, b2 cipher.Block, s int) hash.Hash {
+	checkMACBlock(b1)
+	checkMACBlock(b2)
+	if s < 1 || s > 7 {
+		panic("twine: LightMAC counter size must be 1 to 7 bytes")
+	}
+
+	m := &lightmac{b1: b1, b2: b2, s: s, max: 1<<(8*uint(s)) - 1}
+	m.mb, _ = b1.(MultiBlock)
+	return m
+}
+
+func (m *lightmac) Reset() {
+	if m.wiped {
+		panic(errWipedMode)
+	}
+	m.ctr = 0
+	m.v = 0
+	m.nq = 0
+	m.nx = 0
+	m.overflow = false
+}
+
+// Wipe scrubs the accumulated sum and buffered input.  The MAC must not be
+// used afterwards: any further call panics.
+func (m *lightmac) Wipe() {
+	m.v = 0
+	Wipe(m.q[:])
+	Wipe(m.buf[:])
+	m.wiped = true
+}
+
+func (m *lightmac) Size() int { return 8 }
+
+func (m *lightmac) BlockSize() int { return 8 - m.s }
+
+// Write queues all but the last message block, which Sum pads
+func (m *lightmac) Write(p []byte) (int, error) {
+	if m.wiped {
+		panic(errWipedMode)
+	}
+	n := len(p)
+	w := 8 - m.s
+
+	for len(p) > 0 && !m.overflow {
+		if m.nx == w {
+			if m.ctr == m.max {
+				m.overflow = true
+				break
+			}
+			m.queue()
+			m.nx = 0
+		}
+		k := copy(m.buf[m.nx:w], p)
+		m.nx += k
+		p = p[k:]
+	}
+
+	return n, nil
+}
+
+func (m *lightmac) queue() {
+	m.ctr++
+	var c [8]byte
+	binary.BigEndian.PutUint64(c[:], m.ctr)
+	in := m.q[m.nq : m.nq+8]
+	copy(in, c[8-m.s:])
+	copy(in[m.s:], m.buf[:8-m.s])
+	m.nq += 8
+	if m.nq == len(m.q) {
+		m.flush()
+	}
+}
+
+func (m *lightmac) flush() {
+	q := m.q[:m.nq]
+	if m.mb != nil {
+		m.mb.EncryptBlocks(q, q)
+	} else {
+		for i := 0; i < len(q); i += 8 {
+			m.b1.Encrypt(q[i:i+8], q[i:i+8])
+		}
+	}
+	for i := 0; i < len(q); i += 8 {
+		m.v ^= binary.BigEndian.Uint64(q[i:])
+	}
+	m.nq = 0
+}
+
+func (m *lightmac) Sum(in []byte) []byte {
+	if m.wiped {
+		panic(errWipedMode)
+	}
+	if m.overflow {
+		panic(ErrCounterOverflow)
+	}
+
+	// work on a copy so the caller can keep writing
+	c := *m
+	c.flush()
+
+	var last [8]byte
+	copy(last[:], c.buf[:c.nx])
+	last[c.nx] = 0x80
+
+	var tag [8]byte
+	binary.BigEndian.PutUint64(tag[:], c.v^binary.BigEndian.Uint64(last[:]))
+	c.b2.Encrypt(tag[:], tag[:])
+
+	return append(in, tag[:]...)
+}
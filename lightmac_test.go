@@ -0,0 +1,82 @@
+package twine
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+// lightmacRef is LightMAC written directly from its definition
+func lightmacRef(b1, b2 cipher.Block, s int, m []byte) []byte {
+	w := 8 - s
+
+	var v uint64
+	i := uint64(1)
+	for ; len(m) > w; m, i = m[w:], i+1 {
+		var x [8]byte
+		binary.BigEndian.PutUint64(x[:], i<<(8*uint(w)))
+		copy(x[s:], m[:w])
+		b1.Encrypt(x[:], x[:])
+		v ^= binary.BigEndian.Uint64(x[:])
+	}
+
+	var last [8]byte
+	copy(last[:], m)
+	last[len(m)] = 0x80
+	binary.BigEndian.PutUint64(last[:], v^binary.BigEndian.Uint64(last[:]))
+	b2.Encrypt(last[:], last[:])
+	return last[:]
+}
+
+func TestLightMAC(t *testing.T) {
+
+	b1, _ := New(tests[0].key)
+	b2, _ := New(tests[1].key)
+
+	msg := make([]byte, 1500)
+	for i := range msg {
+		msg[i] = byte(i * 3)
+	}
+
+	for s := 1; s <= 7; s++ {
+		for _, n := range []int{0, 1, 8 - s, 9 - s, 8 * (8 - s), len(msg)} {
+			want := lightmacRef(b1, b2, s, msg[:n])
+
+			m := NewLightMAC(b1, b2, s)
+			m.Write(msg[:n/2])
+			m.Write(msg[n/2 : n])
+			if got := m.Sum(nil); !bytes.Equal(got, want) {
+				t.Errorf("s=%d, %d bytes: LightMAC = %x, want %x", s, n, got, want)
+			}
+
+			m = NewLightMAC(struct{ cipher.Block }{b1}, b2, s)
+			m.Write(msg[:n])
+			if got := m.Sum(nil); !bytes.Equal(got, want) {
+				t.Errorf("s=%d, %d bytes without batching: LightMAC = %x, want %x", s, n, got, want)
+			}
+		}
+	}
+
+	// a 1-byte counter covers 255 blocks of 7 bytes, plus the last block
+	m := NewLightMAC(b1, b2, 1)
+	m.Write(make([]byte, 256*7))
+	m.Sum(nil)
+
+	// one byte more, even through io.Copy, and Sum refuses
+	if n, err := io.Copy(m, bytes.NewReader([]byte{0})); n != 1 || err != nil {
+		t.Errorf("overlong Write = %d, %v", n, err)
+	}
+	mustPanic(t, "Sum of overlong message", func() { m.Sum(nil) })
+
+	m.Reset()
+	m.Write([]byte("short"))
+	m.Sum(nil)
+
+	m.(Wiper).Wipe()
+	if l := m.(*lightmac); l.buf != [8]byte{} {
+		t.Errorf("buffered input not wiped")
+	}
+	mustPanic(t, "LightMAC Sum after Wipe", func() { m.Sum(nil) })
+}
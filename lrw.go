@@ -0,0 +1,74 @@
+package twine
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+
+	"github.com/dgryski/go-twine/gf64"
+)
+
+// LRW is the tweakable mode of Liskov, Rivest and Wagner as used by legacy
+// storage formats: block i is encrypted as E(P xor T) xor T with the mask
+// T = K2 * i in GF(2^64), where K2 is a second, 8-byte key and i is the
+// block's index on the device.  Block index 0 is not masked, as in the
+// IEEE 1619 LRW draft.
+//
+// LRW is superseded by XEX, which should be preferred for new formats: it
+// leaks K2 if the plaintext ever contains K2 itself, and like XEX it
+// provides confidentiality only.
+type LRW struct {
+	b     cipher.Block
+	k2    uint64
+	wiped bool
+}
+
+// NewLRW returns an LRW encrypting with b, which must have a 64-bit block,
+// and masking with the 8-byte tweak key k2.
+func NewLRW(b cipher.Block, k2 []byte) (*LRW, error) {
+	if b.BlockSize() != 8 {
+		return nil, errors.New("twine: LRW requires a 64-bit block cipher")
+	}
+	if len(k2) != 8 {
+		return nil, errors.New("twine: LRW tweak key must be 8 bytes")
+	}
+	return &LRW{b: b, k2: binary.BigEndian.Uint64(k2)}, nil
+}
+
+// Wipe scrubs the tweak key.  The LRW must not be used afterwards: Encrypt
+// and Decrypt panic.
+func (l *LRW) Wipe() {
+	l.k2 = 0
+	l.wiped = true
+}
+
+// Encrypt encrypts src, a whole number of blocks the first of which has
+// index index, into dst.  dst and src may overlap entirely.
+func (l *LRW) Encrypt(dst, src []byte, index uint64) {
+	l.crypt(dst, src, index, EncryptWhitened)
+}
+
+// Decrypt decrypts src, a whole number of blocks the first of which has
+// index index, into dst.  dst and src may overlap entirely.
+func (l *LRW) Decrypt(dst, src []byte, index uint64) {
+	l.crypt(dst, src, index, DecryptWhitened)
+}
+
+func (l *LRW) crypt(dst, src []byte, index uint64, fn func(b cipher.Block, dst, src, pre, post []byte)) {
+	if l.wiped {
+		panic(errWipedMode)
+	}
+	if len(src)%8 != 0 {
+		panic("twine: LRW input not full blocks")
+	}
+	if len(dst) < len(src) {
+		panic("twine: output smaller than input")
+	}
+
+	var t [8]byte
+	for i := 0; i < len(src); i += 8 {
+		binary.BigEndian.PutUint64(t[:], gf64.Mul(l.k2, index))
+		fn(l.b, dst[i:], src[i:], t[:], t[:])
+		index++
+	}
+}
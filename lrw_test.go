@@ -0,0 +1,64 @@
+package twine
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dgryski/go-twine/gf64"
+)
+
+func TestLRW(t *testing.T) {
+
+	b, _ := New(tests[1].key)
+	k2 := unhex("0123456789abcdef")
+	l, err := NewLRW(b, k2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := make([]byte, 40)
+	for i := range src {
+		src[i] = byte(i * 7)
+	}
+
+	got := make([]byte, len(src))
+	l.Encrypt(got, src, 5)
+
+	// from the definition
+	for i := 0; i < len(src); i += 8 {
+		var mask [8]byte
+		m := gf64.Mul(0x0123456789abcdef, uint64(5+i/8))
+		for j := range mask {
+			mask[j] = byte(m >> (56 - 8*j))
+		}
+		want := make([]byte, 8)
+		EncryptWhitened(b, want, src[i:], mask[:], mask[:])
+		if !bytes.Equal(got[i:i+8], want) {
+			t.Errorf("block %d = %x, want %x", 5+i/8, got[i:i+8], want)
+		}
+	}
+
+	// blocks are independent: a range can be re-encrypted on its own
+	part := make([]byte, 16)
+	l.Encrypt(part, src[16:32], 7)
+	if !bytes.Equal(part, got[16:32]) {
+		t.Errorf("partial range = %x, want %x", part, got[16:32])
+	}
+
+	l.Decrypt(got, got, 5)
+	if !bytes.Equal(got, src) {
+		t.Errorf("round trip = %x", got)
+	}
+
+	if _, err := NewLRW(b, k2[:7]); err == nil {
+		t.Errorf("7-byte tweak key accepted")
+	}
+	mustPanic(t, "partial block", func() { l.Encrypt(make([]byte, 9), make([]byte, 9), 0) })
+
+	var w Wiper = l
+	w.Wipe()
+	if l.k2 != 0 {
+		t.Errorf("tweak key not wiped")
+	}
+	mustPanic(t, "LRW Encrypt after Wipe", func() { l.Encrypt(make([]byte, 8), make([]byte, 8), 1) })
+}
@@ -0,0 +1,25 @@
+package twine
+
+import "encoding/binary"
+
+// MGF fills out with the MGF1-style mask generated from seed using the
+// Davies-Meyer hash: out is the concatenation of H(seed || C) for a 4-byte
+// big-endian counter C starting at zero, truncated to len(out).
+func MGF(out, seed []byte) {
+
+	h := NewDM()
+
+	var ctr [4]byte
+	var sum [8]byte
+
+	for i, c := 0, uint32(0); i < len(out); i, c = i+len(sum), c+1 {
+		binary.BigEndian.PutUint32(ctr[:], c)
+
+		h.Reset()
+		h.Write(seed)
+		h.Write(ctr[:])
+		binary.BigEndian.PutUint64(sum[:], h.Sum64())
+
+		copy(out[i:], sum[:])
+	}
+}
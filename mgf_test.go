@@ -0,0 +1,37 @@
+package twine
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMGF(t *testing.T) {
+
+	seed := []byte("mask seed")
+
+	long := make([]byte, 100)
+	MGF(long, seed)
+
+	// shorter masks are prefixes of longer ones
+	for n := 0; n < len(long); n++ {
+		out := make([]byte, n)
+		MGF(out, seed)
+		if !bytes.Equal(out, long[:n]) {
+			t.Fatalf("len %d is not a prefix of the longer mask", n)
+		}
+	}
+
+	// first block is H(seed || 0x00000000)
+	h := NewDM()
+	h.Write(seed)
+	h.Write([]byte{0, 0, 0, 0})
+	if !bytes.Equal(h.Sum(nil), long[:8]) {
+		t.Errorf("first block % 02x, want % 02x", long[:8], h.Sum(nil))
+	}
+
+	other := make([]byte, len(long))
+	MGF(other, []byte("other seed"))
+	if bytes.Equal(other, long) {
+		t.Error("different seeds produced the same mask")
+	}
+}
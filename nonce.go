@@ -0,0 +1,88 @@
+package twine
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+)
+
+// NonceSize is the size of the nonces produced by a NonceSource.
+const NonceSize = 8
+
+const nonceMaxCounter = 1<<32 - 1
+
+// NonceSource generates hedged nonces.  Each nonce is E_s(counter || r),
+// where s is a per-device salt, counter is 32 bits and r is 32 fresh random
+// bits.  Since E_s is a permutation, nonces are unique while the counter is
+// monotonic, even if the random source fails; if the counter is rolled back
+// while the random source works, a nonce repeats only when r does too, with
+// probability 2^-32 for each reused counter value.  A NonceSource issues
+// 2^32-1 nonces.  It is safe for concurrent use, provided its random source
+// is.
+type NonceSource struct {
+	mu   sync.Mutex
+	b    cipher.Block
+	ctr  uint64
+	rand io.Reader
+}
+
+// NewNonceSource returns a NonceSource keyed with the per-device salt, which
+// should be 10 or 16 bytes.  The counter argument is the last value returned
+// by Counter, persisted by the caller across restarts.  If r is nil,
+// crypto/rand.Reader is used.
+//
+// Unlike the other keyed constructors, NewNonceSource has no FromReader
+// form, as the salt need not be secret: uniqueness does not depend on it,
+// and anyone who knows it learns only the counter behind each nonce.
+func NewNonceSource(salt []byte, counter uint64, r io.Reader) (*NonceSource, error) {
+
+	b, err := New(salt)
+	if err != nil {
+		return nil, err
+	}
+
+	if r == nil {
+		r = rand.Reader
+	}
+
+	return &NonceSource{b: b, ctr: counter, rand: r}, nil
+}
+
+// Next writes a new nonce into the first NonceSize bytes of nonce.  Once the
+// counter is exhausted it returns ErrCounterOverflow.
+func (s *NonceSource) Next(nonce []byte) error {
+
+	if len(nonce) < NonceSize {
+		return errors.New("twine: nonce buffer too short")
+	}
+
+	var x [NonceSize]byte
+	if _, err := io.ReadFull(s.rand, x[4:]); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	if s.ctr >= nonceMaxCounter {
+		s.mu.Unlock()
+		return ErrCounterOverflow
+	}
+	s.ctr++
+	ctr := s.ctr
+	s.mu.Unlock()
+
+	binary.BigEndian.PutUint32(x[:4], uint32(ctr))
+	s.b.Encrypt(nonce[:NonceSize], x[:])
+
+	return nil
+}
+
+// Counter returns the current counter value.  Callers should persist it and
+// pass it to NewNonceSource on restart.
+func (s *NonceSource) Counter() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ctr
+}
@@ -0,0 +1,97 @@
+package twine
+
+import "encoding/binary"
+
+// WithOnTheFlyKeySchedule makes New return a cipher that stores only the
+// key schedule register, at the first and the last round, instead of all 36
+// round keys in their several forms: about 100 bytes rather than nearly
+// 1.2KB.  Encrypt and Decrypt derive each round key as they go, running the
+// schedule backwards to decrypt, which roughly doubles their cost.
+//
+// The returned cipher.Block implements Wiper and has a Params method, but
+// does not implement MultiBlock, and WithTables has no effect on it.
+// WithConstantTime does.
+func WithOnTheFlyKeySchedule() Option {
+	return func(o *options) { o.onTheFly = true }
+}
+
+type twineLite struct {
+	start, end keyState
+	round      func(uint64, *[8]byte) uint64
+	sb         func(byte) byte
+	wiped      bool
+}
+
+func newLite(key []byte, constantTime bool) *twineLite {
+
+	t := &twineLite{round: roundF, sb: sboxTable}
+	if constantTime {
+		t.round, t.sb = roundCT, sboxCT
+	}
+
+	t.start.init(key)
+	t.end = t.start
+	for i := 0; i < 35; i++ {
+		t.end.step(i, t.sb)
+	}
+
+	return t
+}
+
+func (t *twineLite) BlockSize() int { return 8 }
+
+// Params returns the security parameters of the cipher.
+func (t *twineLite) Params() Params { return params(t.start.n / 2) }
+
+func (t *twineLite) Encrypt(dst, src []byte) {
+
+	if t.wiped {
+		panic(errWiped)
+	}
+
+	x := binary.BigEndian.Uint64(src)
+
+	ks := t.start
+	defer ks.wipe()
+
+	var rk [8]byte
+	for i := 0; i < 35; i++ {
+		ks.roundKey(&rk)
+		x = shuffle(t.round(x, &rk))
+		ks.step(i, t.sb)
+	}
+	ks.roundKey(&rk)
+	x = t.round(x, &rk)
+
+	binary.BigEndian.PutUint64(dst, x)
+}
+
+func (t *twineLite) Decrypt(dst, src []byte) {
+
+	if t.wiped {
+		panic(errWiped)
+	}
+
+	x := binary.BigEndian.Uint64(src)
+
+	ks := t.end
+	defer ks.wipe()
+
+	var rk [8]byte
+	for i := 35; i >= 1; i-- {
+		ks.roundKey(&rk)
+		x = unshuffle(t.round(x, &rk))
+		ks.unstep(i-1, t.sb)
+	}
+	ks.roundKey(&rk)
+	x = t.round(x, &rk)
+
+	binary.BigEndian.PutUint64(dst, x)
+}
+
+// Wipe scrubs the stored key schedule registers.
+func (t *twineLite) Wipe() {
+	t.start.wipe()
+	t.end.wipe()
+	t.wiped = true
+}
@@ -0,0 +1,78 @@
+package twine
+
+import (
+	"bytes"
+	"runtime"
+	"testing"
+	"unsafe"
+)
+
+func TestKeyStateUnstep(t *testing.T) {
+	for _, tt := range tests {
+		var ks keyState
+		ks.init(tt.key)
+		start := ks
+		for i := 0; i < 35; i++ {
+			ks.step(i, sboxTable)
+		}
+		for i := 34; i >= 0; i-- {
+			ks.unstep(i, sboxTable)
+		}
+		if ks != start {
+			t.Errorf("%d-bit key: unstep did not invert step", 8*len(tt.key))
+		}
+	}
+}
+
+func TestOnTheFlyKeySchedule(t *testing.T) {
+
+	for _, tt := range tests {
+		for _, opts := range [][]Option{
+			{WithOnTheFlyKeySchedule()},
+			{WithOnTheFlyKeySchedule(), WithConstantTime()},
+		} {
+			b, err := New(tt.key, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := b.(*twineLite); !ok {
+				t.Fatalf("New returned %T", b)
+			}
+
+			var ct, pt [8]byte
+			b.Encrypt(ct[:], tt.plain)
+			if !bytes.Equal(ct[:], tt.cipher) {
+				t.Errorf("%d-bit key: Encrypt = %x, want %x", 8*len(tt.key), ct, tt.cipher)
+			}
+			b.Decrypt(pt[:], ct[:])
+			if !bytes.Equal(pt[:], tt.plain) {
+				t.Errorf("%d-bit key: Decrypt = %x, want %x", 8*len(tt.key), pt, tt.plain)
+			}
+			if p := b.(interface{ Params() Params }).Params(); p.KeySize != len(tt.key) {
+				t.Errorf("Params().KeySize = %d, want %d", p.KeySize, len(tt.key))
+			}
+		}
+	}
+}
+
+func TestOnTheFlySize(t *testing.T) {
+	if n := unsafe.Sizeof(twineLite{}); n > 128 {
+		t.Errorf("twineLite is %d bytes, documented as about 100", n)
+	}
+	if n := unsafe.Sizeof(twineCipher{}); n < 1100 || n > 1200 {
+		t.Errorf("twineCipher is %d bytes, documented as nearly 1.2KB", n)
+	}
+
+	// New must not allocate a full cipher before choosing the small one.
+	const runs = 100
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	for i := 0; i < runs; i++ {
+		New(tests[1].key, WithOnTheFlyKeySchedule())
+	}
+	runtime.ReadMemStats(&after)
+	if per := (after.TotalAlloc - before.TotalAlloc) / runs; per > 256 {
+		t.Errorf("New with WithOnTheFlyKeySchedule allocates %d bytes", per)
+	}
+}
@@ -0,0 +1,157 @@
+package twine
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"hash"
+	"math/bits"
+
+	"github.com/dgryski/go-twine/gf64"
+)
+
+// pmacBatch is the number of blocks PMAC queues for a single EncryptBlocks
+// call; a multiple of the bitsliced batch
+const pmacBatch = 2 * bitsliceBlocks
+
+// pmac implements PMAC1 (Rogaway) for a 64-bit block cipher
+type pmac struct {
+	b  cipher.Block
+	mb MultiBlock // b, if it can encrypt in batches
+
+	l     [64]uint64 // L(i) = x^i * E(0)
+	linv  uint64     // x^-1 * E(0)
+	delta uint64
+	sigma uint64
+	i     uint64 // blocks offset so far
+
+	q  [8 * pmacBatch]byte // masked blocks awaiting encryption
+	nq int
+
+	buf [8]byte // last, possibly partial, block
+	nx  int
+
+	wiped bool
+}
+
+// NewPMAC returns a hash.Hash computing PMAC1 under b, which must have a
+// 64-bit block.  Unlike CMAC, the block cipher calls for different message
+// blocks are independent: when b implements MultiBlock, as the ciphers
+// returned by New do, they are made in batches through EncryptBlocks and
+// so use its bitsliced and vector backends.  The returned hash also
+// implements Wiper.
+func NewPMAC(b cipher.Block) hash.Hash {
+	if b.BlockSize() != 8 {
+		panic("twine: PMAC requires a 64-bit block cipher")
+	}
+
+	p := &pmac{b: b}
+	p.mb, _ = b.(MultiBlock)
+
+	var l [8]byte
+	b.Encrypt(l[:], l[:])
+	p.l[0] = binary.BigEndian.Uint64(l[:])
+	Wipe(l[:])
+	for i := 1; i < len(p.l); i++ {
+		p.l[i] = gf64.Double(p.l[i-1])
+	}
+	p.linv = gf64.Half(p.l[0])
+
+	return p
+}
+
+func (p *pmac) Reset() {
+	if p.wiped {
+		panic(errWipedMode)
+	}
+	p.delta = 0
+	p.sigma = 0
+	p.i = 0
+	p.nq = 0
+	p.nx = 0
+}
+
+// Wipe scrubs the offsets and buffered input.  The MAC must not be used
+// afterwards: any further call panics.
+func (p *pmac) Wipe() {
+	p.l = [64]uint64{}
+	p.linv, p.delta, p.sigma = 0, 0, 0
+	Wipe(p.q[:])
+	Wipe(p.buf[:])
+	p.wiped = true
+}
+
+func (p *pmac) Size() int { return 8 }
+
+func (p *pmac) BlockSize() int { return 8 }
+
+// Write queues all but the last block, which Sum treats specially
+func (p *pmac) Write(m []byte) (int, error) {
+	if p.wiped {
+		panic(errWipedMode)
+	}
+	n := len(m)
+
+	for len(m) > 0 {
+		if p.nx == 8 {
+			p.queue(p.buf[:])
+			p.nx = 0
+		}
+		k := copy(p.buf[p.nx:], m)
+		p.nx += k
+		m = m[k:]
+	}
+
+	return n, nil
+}
+
+func (p *pmac) queue(m []byte) {
+	p.i++
+	p.delta ^= p.l[bits.TrailingZeros64(p.i)]
+	binary.BigEndian.PutUint64(p.q[p.nq:], binary.BigEndian.Uint64(m)^p.delta)
+	p.nq += 8
+	if p.nq == len(p.q) {
+		p.flush()
+	}
+}
+
+// flush encrypts the queued blocks and folds them into sigma
+func (p *pmac) flush() {
+	q := p.q[:p.nq]
+	if p.mb != nil {
+		p.mb.EncryptBlocks(q, q)
+	} else {
+		for i := 0; i < len(q); i += 8 {
+			p.b.Encrypt(q[i:i+8], q[i:i+8])
+		}
+	}
+	for i := 0; i < len(q); i += 8 {
+		p.sigma ^= binary.BigEndian.Uint64(q[i:])
+	}
+	p.nq = 0
+}
+
+func (p *pmac) Sum(in []byte) []byte {
+	if p.wiped {
+		panic(errWipedMode)
+	}
+
+	// work on a copy so the caller can keep writing
+	c := *p
+	c.flush()
+
+	var last [8]byte
+	copy(last[:], c.buf[:c.nx])
+	sigma := c.sigma
+	if c.nx == 8 {
+		sigma ^= binary.BigEndian.Uint64(last[:]) ^ c.linv
+	} else {
+		last[c.nx] = 0x80
+		sigma ^= binary.BigEndian.Uint64(last[:])
+	}
+
+	var tag [8]byte
+	binary.BigEndian.PutUint64(tag[:], sigma)
+	c.b.Encrypt(tag[:], tag[:])
+
+	return append(in, tag[:]...)
+}
@@ -0,0 +1,104 @@
+package twine
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"encoding/binary"
+	"math/bits"
+	"testing"
+
+	"github.com/dgryski/go-twine/gf64"
+)
+
+// pmacRef is PMAC1 written directly from its definition
+func pmacRef(b cipher.Block, m []byte) []byte {
+
+	enc := func(x uint64) uint64 {
+		var t [8]byte
+		binary.BigEndian.PutUint64(t[:], x)
+		b.Encrypt(t[:], t[:])
+		return binary.BigEndian.Uint64(t[:])
+	}
+
+	l := enc(0)
+	lx := func(i int) uint64 {
+		v := l
+		for ; i > 0; i-- {
+			v = gf64.Double(v)
+		}
+		return v
+	}
+
+	nblocks := (len(m) + 7) / 8
+	if nblocks == 0 {
+		nblocks = 1
+	}
+
+	var delta, sigma uint64
+	for i := 1; i < nblocks; i++ {
+		delta ^= lx(bits.TrailingZeros(uint(i)))
+		sigma ^= enc(binary.BigEndian.Uint64(m[8*(i-1):]) ^ delta)
+	}
+
+	var last [8]byte
+	rest := m[8*(nblocks-1):]
+	copy(last[:], rest)
+	if len(rest) == 8 {
+		sigma ^= binary.BigEndian.Uint64(last[:]) ^ gf64.Half(l)
+	} else {
+		last[len(rest)] = 0x80
+		sigma ^= binary.BigEndian.Uint64(last[:])
+	}
+
+	var tag [8]byte
+	binary.BigEndian.PutUint64(tag[:], enc(sigma))
+	return tag[:]
+}
+
+func TestPMAC(t *testing.T) {
+
+	b, _ := New(tests[1].key)
+
+	msg := make([]byte, 8*3*pmacBatch+13)
+	for i := range msg {
+		msg[i] = byte(i * 7)
+	}
+
+	for _, n := range []int{0, 1, 7, 8, 9, 16, 8 * pmacBatch, 8*pmacBatch + 8, 8*pmacBatch + 9, len(msg)} {
+		want := pmacRef(b, msg[:n])
+
+		m := NewPMAC(b)
+		m.Write(msg[:n])
+		if got := m.Sum(nil); !bytes.Equal(got, want) {
+			t.Errorf("%d bytes: PMAC = %x, want %x", n, got, want)
+		}
+
+		// byte-at-a-time, without the batch path
+		m = NewPMAC(struct{ cipher.Block }{b})
+		for i := 0; i < n; i++ {
+			m.Write(msg[i : i+1])
+		}
+		if got := m.Sum(nil); !bytes.Equal(got, want) {
+			t.Errorf("%d bytes written singly: PMAC = %x, want %x", n, got, want)
+		}
+	}
+
+	w, _ := New(tests[1].key)
+	m := NewPMAC(w)
+	m.Write([]byte("secret"))
+	m.(Wiper).Wipe()
+	if p := m.(*pmac); p.l[0] != 0 || p.buf != [8]byte{} {
+		t.Errorf("offsets or buffered input not wiped")
+	}
+	mustPanic(t, "PMAC Sum after Wipe", func() { m.Sum(nil) })
+}
+
+func BenchmarkPMAC(b *testing.B) {
+	c, _ := New(tests[1].key)
+	m := NewPMAC(c)
+	buf := make([]byte, 8192)
+	b.SetBytes(int64(len(buf)))
+	for i := 0; i < b.N; i++ {
+		m.Write(buf)
+	}
+}
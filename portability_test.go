@@ -0,0 +1,85 @@
+package twine
+
+import (
+	"bytes"
+	"testing"
+)
+
+// Portability tests: inputs at every alignment, inputs backed by read-only
+// memory, and in-place operation.  The implementation has no byte-order
+// dependence beyond the known-answer vectors; to check a big-endian target,
+// cross-compile and run the suite under emulation, e.g.
+//
+//	GOARCH=s390x go test -exec qemu-s390x
+
+func TestUnalignedBuffers(t *testing.T) {
+
+	for _, tst := range tests {
+		c, _ := New(tst.key)
+
+		for off := 0; off < 8; off++ {
+			src := make([]byte, 8+off)[off:]
+			copy(src, tst.plain)
+			dst := make([]byte, 8+(7-off))[7-off:]
+
+			c.Encrypt(dst, src)
+			if !bytes.Equal(dst, tst.cipher) {
+				t.Errorf("offset %d: encrypt failed:\ngot : % 02x\nwant: % 02x", off, dst, tst.cipher)
+			}
+
+			c.Decrypt(src, dst)
+			if !bytes.Equal(src, tst.plain) {
+				t.Errorf("offset %d: decrypt failed:\ngot : % 02x\nwant: % 02x", off, src, tst.plain)
+			}
+		}
+	}
+}
+
+func TestReadOnlySource(t *testing.T) {
+
+	for _, tst := range tests {
+		c, _ := New(tst.key)
+
+		src := readOnlyCopy(t, tst.plain)
+		var ct [8]byte
+		c.Encrypt(ct[:], src)
+		if !bytes.Equal(ct[:], tst.cipher) {
+			t.Errorf("encrypt failed:\ngot : % 02x\nwant: % 02x", ct[:], tst.cipher)
+		}
+
+		src = readOnlyCopy(t, tst.cipher)
+		var p [8]byte
+		c.Decrypt(p[:], src)
+		if !bytes.Equal(p[:], tst.plain) {
+			t.Errorf("decrypt failed:\ngot : % 02x\nwant: % 02x", p[:], tst.plain)
+		}
+	}
+
+	v, _ := NewVarBlock(tests[0].key)
+	msg := []byte("read-only short identifier")
+	want := make([]byte, len(msg))
+	v.Encrypt(want, msg)
+
+	got := make([]byte, len(msg))
+	v.Encrypt(got, readOnlyCopy(t, msg))
+	if !bytes.Equal(got, want) {
+		t.Error("VarBlock output differs for read-only input")
+	}
+}
+
+func TestInPlace(t *testing.T) {
+
+	for _, tst := range tests {
+		c, _ := New(tst.key)
+
+		buf := append([]byte(nil), tst.plain...)
+		c.Encrypt(buf, buf)
+		if !bytes.Equal(buf, tst.cipher) {
+			t.Errorf("in-place encrypt failed:\ngot : % 02x\nwant: % 02x", buf, tst.cipher)
+		}
+		c.Decrypt(buf, buf)
+		if !bytes.Equal(buf, tst.plain) {
+			t.Errorf("in-place decrypt failed:\ngot : % 02x\nwant: % 02x", buf, tst.plain)
+		}
+	}
+}
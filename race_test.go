@@ -0,0 +1,347 @@
+package twine
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"sync"
+	"testing"
+	"time"
+)
+
+// These tests share a single keyed instance between goroutines; run them
+// with -race to check the concurrency guarantees documented on each type.
+
+const raceGoroutines = 8
+
+func runConcurrently(f func(g int)) {
+	var wg sync.WaitGroup
+	for g := 0; g < raceGoroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			f(g)
+		}(g)
+	}
+	wg.Wait()
+}
+
+// raceBackends are the cipher configurations shared between goroutines
+var raceBackends = []struct {
+	name string
+	opts []Option
+}{
+	{"default", nil},
+	{"tables", []Option{WithTables()}},
+	{"consttime", []Option{WithConstantTime()}},
+	{"onthefly", []Option{WithOnTheFlyKeySchedule()}},
+	{"onthefly-consttime", []Option{WithOnTheFlyKeySchedule(), WithConstantTime()}},
+}
+
+func TestConcurrentBlock(t *testing.T) {
+
+	for _, be := range raceBackends {
+		for _, tst := range tests {
+			c, _ := New(tst.key, be.opts...)
+
+			runConcurrently(func(int) {
+				for i := 0; i < 100; i++ {
+					var ct, p [8]byte
+					c.Encrypt(ct[:], tst.plain)
+					c.Decrypt(p[:], ct[:])
+					if !bytes.Equal(ct[:], tst.cipher) || !bytes.Equal(p[:], tst.plain) {
+						t.Errorf("%s: concurrent encrypt/decrypt mismatch", be.name)
+						return
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestConcurrentAEAD(t *testing.T) {
+
+	b, _ := New(tests[1].key)
+	b2, _ := New(tests[0].key)
+	eax, _ := NewEAX(b)
+	ccm, _ := NewCCM(b, 4, 8)
+	siv, _ := NewSIV(b, b2, 8)
+
+	for name, a := range map[string]cipher.AEAD{"EAX": eax, "CCM": ccm, "SIV": siv} {
+		nonce := make([]byte, a.NonceSize())
+		runConcurrently(func(g int) {
+			p := bytes.Repeat([]byte{byte(g)}, 8*g+3)
+			for i := 0; i < 50; i++ {
+				nonce := append([]byte(nil), nonce...)
+				nonce[0] = byte(g)
+				ct := a.Seal(nil, nonce, p, nil)
+				got, err := a.Open(nil, nonce, ct, nil)
+				if err != nil || !bytes.Equal(got, p) {
+					t.Errorf("%s: concurrent seal/open failed", name)
+					return
+				}
+			}
+		})
+	}
+}
+
+func TestConcurrentCacheUse(t *testing.T) {
+
+	c := NewCache(time.Millisecond, 2)
+	load := func(id string) ([]byte, error) {
+		return []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, id[0]}, nil
+	}
+
+	runConcurrently(func(g int) {
+		for i := 0; i < 100; i++ {
+			id := string(rune('a' + (g+i)%4))
+			err := c.Use(id, load, func(b cipher.Block) error {
+				var out [8]byte
+				b.Encrypt(out[:], out[:])
+				return nil
+			})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if i%10 == 0 {
+				c.Sweep()
+			}
+		}
+	})
+}
+
+func TestConcurrentArena(t *testing.T) {
+
+	a := NewCipherArena(4)
+
+	runConcurrently(func(int) {
+		for i := 0; i < 20; i++ {
+			c, err := a.New(tests[1].key)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			var ct [8]byte
+			c.Encrypt(ct[:], tests[1].plain)
+			if !bytes.Equal(ct[:], tests[1].cipher) {
+				t.Error("concurrent arena cipher mismatch")
+				return
+			}
+		}
+	})
+
+	if a.Len() != 20*raceGoroutines {
+		t.Errorf("arena holds %d ciphers, want %d", a.Len(), 20*raceGoroutines)
+	}
+}
+
+func TestConcurrentCompactKeys(t *testing.T) {
+
+	c := NewCompactKeys(2)
+	for _, id := range []string{"a", "b", "c", "d"} {
+		c.Add(id, tests[1].key)
+	}
+
+	runConcurrently(func(g int) {
+		for i := 0; i < 100; i++ {
+			b, err := c.Block(string(rune('a' + (g+i)%4)))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			var ct [8]byte
+			b.Encrypt(ct[:], tests[1].plain)
+			if !bytes.Equal(ct[:], tests[1].cipher) {
+				t.Error("concurrent CompactKeys mismatch")
+				return
+			}
+		}
+	})
+}
+
+func TestConcurrentChallenger(t *testing.T) {
+
+	master, _ := New(tests[1].key)
+	c := NewChallenger(master, time.Minute, nil)
+
+	runConcurrently(func(g int) {
+		id := []byte{byte(g)}
+		dev, _ := New(DeviceKey(master, id))
+		for i := 0; i < 50; i++ {
+			ch, err := c.Challenge(id)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if err := c.Verify(id, ch, nil, Respond(dev, ch, nil)); err != nil {
+				t.Error("concurrent challenge rejected:", err)
+				return
+			}
+		}
+	})
+}
+
+func TestConcurrentCommandVerifier(t *testing.T) {
+
+	b, _ := New(tests[1].key)
+	v := NewCommandVerifier(b, 1, 0)
+
+	var mu sync.Mutex
+	var seq uint64
+	accepted := make(map[uint64]bool)
+
+	runConcurrently(func(int) {
+		for i := 0; i < 50; i++ {
+			mu.Lock()
+			seq++
+			c := Command{Scope: 1, Expires: time.Now().Add(time.Minute), Seq: seq}
+			mu.Unlock()
+			got, err := v.Verify(c.Seal(b))
+			if err != nil {
+				// a later command may have been accepted first
+				continue
+			}
+			mu.Lock()
+			if accepted[got.Seq] {
+				t.Error("command accepted twice")
+			}
+			accepted[got.Seq] = true
+			mu.Unlock()
+		}
+	})
+
+	if v.Seq() == 0 {
+		t.Error("no command accepted")
+	}
+}
+
+func TestConcurrentRollingCodeReceiver(t *testing.T) {
+
+	b, _ := New(tests[1].key)
+	tx := NewRollingCode(b, 0)
+	rx := NewRollingCodeReceiver(b, 0, 16, 256)
+
+	codes := make([]uint32, raceGoroutines*10)
+	for i := range codes {
+		codes[i], _ = tx.Next()
+	}
+
+	var mu sync.Mutex
+	accepted := 0
+	runConcurrently(func(g int) {
+		for i := g; i < len(codes); i += raceGoroutines {
+			if rx.Accept(codes[i]) {
+				mu.Lock()
+				accepted++
+				mu.Unlock()
+			}
+		}
+	})
+
+	if accepted == 0 || accepted > len(codes) {
+		t.Errorf("accepted %d of %d codes", accepted, len(codes))
+	}
+	if rx.Accept(codes[len(codes)-1]) {
+		t.Error("replayed code accepted")
+	}
+}
+
+func TestConcurrentVarBlock(t *testing.T) {
+
+	v, _ := NewVarBlock(tests[1].key)
+
+	want := make([][]byte, raceGoroutines)
+	for g := range want {
+		want[g] = make([]byte, g+1)
+		v.Encrypt(want[g], bytes.Repeat([]byte{byte(g)}, g+1))
+	}
+
+	runConcurrently(func(g int) {
+		p := bytes.Repeat([]byte{byte(g)}, g+1)
+		c := make([]byte, len(p))
+		for i := 0; i < 100; i++ {
+			v.Encrypt(c, p)
+			if !bytes.Equal(c, want[g]) {
+				t.Error("concurrent VarBlock mismatch")
+				return
+			}
+		}
+	})
+}
+
+func TestConcurrentDatasetShuffler(t *testing.T) {
+
+	s, _ := NewDatasetShuffler(tests[0].key, 1000)
+
+	runConcurrently(func(g int) {
+		for i := uint64(g); i < 1000; i += raceGoroutines {
+			if s.Position(s.Index(i)) != i {
+				t.Error("concurrent shuffle mismatch")
+				return
+			}
+		}
+	})
+}
+
+func TestConcurrentNonceSource(t *testing.T) {
+
+	s, _ := NewNonceSource(tests[0].key, 0, zeroReader{})
+
+	var mu sync.Mutex
+	seen := make(map[[NonceSize]byte]bool)
+
+	runConcurrently(func(int) {
+		for i := 0; i < 100; i++ {
+			var n [NonceSize]byte
+			s.Next(n[:])
+			mu.Lock()
+			if seen[n] {
+				t.Error("concurrent nonce repeated")
+			}
+			seen[n] = true
+			mu.Unlock()
+		}
+	})
+}
+
+func TestConcurrentCache(t *testing.T) {
+
+	c := NewCache(time.Minute, 4)
+	load := func(id string) ([]byte, error) {
+		return []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, id[0]}, nil
+	}
+
+	runConcurrently(func(g int) {
+		for i := 0; i < 100; i++ {
+			id := string(rune('a' + (g+i)%6))
+			if _, err := c.Get(id, load); err != nil {
+				t.Error(err)
+				return
+			}
+			if i%10 == 0 {
+				c.Remove(id)
+			}
+		}
+	})
+}
+
+func TestConcurrentMultiBlock(t *testing.T) {
+
+	b, _ := New(tests[1].key)
+	m := b.(MultiBlock)
+
+	src := make([]byte, 8*bitsliceBlocks+8)
+	want := make([]byte, len(src))
+	m.EncryptBlocks(want, src)
+
+	runConcurrently(func(int) {
+		got := make([]byte, len(src))
+		for i := 0; i < 20; i++ {
+			m.EncryptBlocks(got, src)
+			if !bytes.Equal(got, want) {
+				t.Error("concurrent EncryptBlocks mismatch")
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,57 @@
+package twine
+
+import "encoding/binary"
+
+// Rand is a seeded pseudo-random generator running TWINE-128 in counter
+// mode.  It satisfies math/rand/v2's Source interface.  Because the output
+// is a permutation of the counter, a stream never repeats a value within
+// 2^64 outputs; this is distinguishable from random after about 2^32
+// outputs, which is irrelevant for simulations but means Rand is not a
+// cryptographic generator for long streams.  Each stream nonce selects its
+// own key, so streams of different nonces are independent.  A Rand is not
+// safe for concurrent use; give each goroutine its own via Split.
+type Rand struct {
+	tw  twineCipher
+	ctr uint64
+}
+
+const randLabel = "twine rand"
+
+// NewRand returns a Rand seeded with seed.  The first 16 bytes are the
+// TWINE-128 master key, the next 8 the initial counter and the last 8 a
+// stream nonce, from which the stream key is derived with the master key.
+func NewRand(seed [32]byte) *Rand {
+	var master twineCipher
+	master.expandKeys(seed[:16])
+	key := deriveKey(&master, randLabel, seed[24:32])
+	defer Wipe(key)
+	master.Wipe()
+
+	r := &Rand{ctr: binary.BigEndian.Uint64(seed[16:24])}
+	r.tw.expandKeys(key)
+	return r
+}
+
+// Uint64 returns a pseudo-random 64-bit value.
+func (r *Rand) Uint64() uint64 {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], r.ctr)
+	r.ctr++
+	r.tw.Encrypt(b[:], b[:])
+	return binary.BigEndian.Uint64(b[:])
+}
+
+// Jump advances the generator by n outputs in constant time.
+func (r *Rand) Jump(n uint64) {
+	r.ctr += n
+}
+
+// Split returns a new generator seeded from the next four outputs of r,
+// for handing independent streams to parallel workers.
+func (r *Rand) Split() *Rand {
+	var seed [32]byte
+	for i := 0; i < 4; i++ {
+		binary.BigEndian.PutUint64(seed[8*i:], r.Uint64())
+	}
+	return NewRand(seed)
+}
@@ -0,0 +1,54 @@
+package twine
+
+import "testing"
+
+func TestRand(t *testing.T) {
+
+	var seed [32]byte
+	for i := range seed {
+		seed[i] = byte(i)
+	}
+
+	r1, r2 := NewRand(seed), NewRand(seed)
+	for i := 0; i < 100; i++ {
+		if r1.Uint64() != r2.Uint64() {
+			t.Fatal("same seed produced different streams")
+		}
+	}
+
+	// Jump must agree with stepping
+	r2.Jump(10)
+	for i := 0; i < 10; i++ {
+		r1.Uint64()
+	}
+	if r1.Uint64() != r2.Uint64() {
+		t.Error("Jump(10) disagrees with 10 calls to Uint64")
+	}
+
+	// streams of different nonces must not overlap, not merely differ in
+	// their first output
+	seed[31] ^= 1
+	r3 := NewRand(seed)
+	seed[31] ^= 1
+	r4 := NewRand(seed)
+	first := make(map[uint64]bool)
+	for i := 0; i < 4096; i++ {
+		first[r4.Uint64()] = true
+	}
+	for i := 0; i < 4096; i++ {
+		if first[r3.Uint64()] {
+			t.Fatalf("output %d of nonce 1 appears in the stream of nonce 0", i)
+		}
+	}
+
+	s := r1.Split()
+	seen := make(map[uint64]bool)
+	for i := 0; i < 1000; i++ {
+		seen[r1.Uint64()] = true
+	}
+	for i := 0; i < 1000; i++ {
+		if seen[s.Uint64()] {
+			t.Fatal("split stream overlaps parent")
+		}
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package twine
+
+import "testing"
+
+// readOnlyCopy returns a plain copy of b on platforms without mmap.
+func readOnlyCopy(t *testing.T, b []byte) []byte {
+	return append([]byte(nil), b...)
+}
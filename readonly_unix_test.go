@@ -0,0 +1,30 @@
+//go:build unix
+
+package twine
+
+import (
+	"syscall"
+	"testing"
+)
+
+// readOnlyCopy returns a copy of b in a page mapped without write
+// permission, so any store through the slice faults.
+func readOnlyCopy(t *testing.T, b []byte) []byte {
+
+	page := syscall.Getpagesize()
+	m, err := syscall.Mmap(-1, 0, page, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { syscall.Munmap(m) })
+
+	// place the data at the end of the page so overruns fault too
+	ro := m[page-len(b):]
+	copy(ro, b)
+
+	if err := syscall.Mprotect(m, syscall.PROT_READ); err != nil {
+		t.Fatal(err)
+	}
+
+	return ro
+}
@@ -0,0 +1,51 @@
+package twine
+
+import "io"
+
+// Resealer re-encrypts a sealed log from one pair of keys to another, for a
+// gateway bridging two trust domains: records sealed under edge keys are
+// verified and resealed under data-center keys one at a time.  Plaintext
+// only ever exists in a single buffer owned by the Resealer, which is wiped
+// after each record.  A Resealer is not safe for concurrent use.
+type Resealer struct {
+	in  *LogOpener
+	out *LogSealer
+	buf []byte
+}
+
+// NewResealer returns a Resealer reading records with in and sealing them
+// with out.  Both should be fresh.
+func NewResealer(in *LogOpener, out *LogSealer) *Resealer {
+	return &Resealer{in: in, out: out}
+}
+
+// Reseal verifies the next sealed record and returns it sealed under the
+// outgoing keys.  On the incoming end-of-log record it returns the outgoing
+// end-of-log record together with io.EOF; the caller must still store it.
+// Errors from the incoming log are those of LogOpener.Open, and nothing is
+// sealed for a record that fails verification.
+func (r *Resealer) Reseal(sealed []byte) ([]byte, error) {
+
+	pt, err := r.in.open(r.buf[:0], sealed)
+	defer Wipe(pt)
+	if cap(pt) > cap(r.buf) {
+		Wipe(r.buf[:cap(r.buf)])
+		r.buf = pt
+	}
+
+	switch {
+	case err == io.EOF:
+		return r.out.Close(), io.EOF
+	case err != nil:
+		return nil, err
+	}
+
+	return r.out.Seal(pt)
+}
+
+// Finish reports whether the incoming log was resealed through its
+// end-of-log record, as LogOpener.Finish does, and wipes the buffer.
+func (r *Resealer) Finish() error {
+	Wipe(r.buf[:cap(r.buf)])
+	return r.in.Finish()
+}
@@ -0,0 +1,71 @@
+package twine
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestResealer(t *testing.T) {
+
+	edgeEnc, _ := New(tests[0].key)
+	edgeMAC, _ := New(tests[1].key)
+	dcEnc, _ := New([]byte("data-center enc!"))
+	dcMAC, _ := New([]byte("data-center mac!"))
+
+	records := [][]byte{[]byte("short"), bytes.Repeat([]byte("long record "), 40), nil, []byte("tail")}
+
+	s, _ := NewLogSealer(edgeEnc, edgeMAC, nil)
+	var edge [][]byte
+	for _, rec := range records {
+		c, _ := s.Seal(rec)
+		edge = append(edge, c)
+	}
+	edge = append(edge, s.Close())
+
+	out, _ := NewLogSealer(dcEnc, dcMAC, nil)
+	r := NewResealer(NewLogOpener(edgeEnc, edgeMAC), out)
+	var dc [][]byte
+	for i, c := range edge {
+		out, err := r.Reseal(c)
+		dc = append(dc, out)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("record %d: %v", i, err)
+		}
+		if len(records[i]) > 0 && bytes.Contains(r.buf[:cap(r.buf)], records[i]) {
+			t.Errorf("record %d: plaintext left in buffer", i)
+		}
+	}
+	if err := r.Finish(); err != nil {
+		t.Fatal(err)
+	}
+
+	o := NewLogOpener(dcEnc, dcMAC)
+	for i, c := range dc {
+		p, err := o.Open(c)
+		if err == io.EOF {
+			break
+		}
+		if err != nil || !bytes.Equal(p, records[i]) {
+			t.Errorf("resealed record %d = %q, %v", i, p, err)
+		}
+	}
+	if err := o.Finish(); err != nil {
+		t.Errorf("resealed log: %v", err)
+	}
+
+	// a forged record is not resealed
+	out, _ = NewLogSealer(dcEnc, dcMAC, nil)
+	r = NewResealer(NewLogOpener(edgeEnc, edgeMAC), out)
+	bad := append([]byte(nil), edge[0]...)
+	bad[9] ^= 1
+	if out, err := r.Reseal(bad); err != ErrAuthFailed || out != nil {
+		t.Errorf("forged record: %x, %v", out, err)
+	}
+	if err := r.Finish(); err != ErrAuthFailed {
+		t.Errorf("Finish after forgery: %v", err)
+	}
+}
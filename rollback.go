@@ -0,0 +1,67 @@
+package twine
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+)
+
+// SealWithCounter seals plaintext with a, binding a monotonic rollback
+// counter into the result, and appends it to dst.  The sealed blob is the
+// big-endian counter, the nonce and the AEAD output; the counter is
+// authenticated as part of the additional data, ahead of additionalData.
+// Use it for configuration blobs that must not be replayed onto a device
+// once it has accepted a newer one.
+func SealWithCounter(a cipher.AEAD, dst, nonce []byte, counter uint64, plaintext, additionalData []byte) []byte {
+	var hdr [8]byte
+	binary.BigEndian.PutUint64(hdr[:], counter)
+
+	dst = append(dst, hdr[:]...)
+	dst = append(dst, nonce...)
+	return a.Seal(dst, nonce, plaintext, rollbackAD(hdr[:], additionalData))
+}
+
+// OpenWithCounter opens a blob made by SealWithCounter and appends the
+// plaintext to dst.  Once the blob is authenticated, accept is called with
+// its counter and may reject it; MinCounter makes a typical hook.  The
+// counter is returned so the caller can persist it as the new minimum
+// after acting on the plaintext.
+func OpenWithCounter(a cipher.AEAD, dst, blob, additionalData []byte, accept func(counter uint64) error) ([]byte, uint64, error) {
+
+	ns := a.NonceSize()
+	if len(blob) < 8+ns+a.Overhead() {
+		return nil, 0, ErrMalformedContainer
+	}
+
+	hdr, nonce, ct := blob[:8], blob[8:8+ns], blob[8+ns:]
+	counter := binary.BigEndian.Uint64(hdr)
+
+	pt, err := a.Open(dst, nonce, ct, rollbackAD(hdr, additionalData))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if accept != nil {
+		if err := accept(counter); err != nil {
+			return nil, counter, err
+		}
+	}
+
+	return pt, counter, nil
+}
+
+// MinCounter returns an OpenWithCounter hook that rejects counters below min
+// with ErrRollback.
+func MinCounter(min uint64) func(uint64) error {
+	return func(counter uint64) error {
+		if counter < min {
+			return ErrRollback
+		}
+		return nil
+	}
+}
+
+func rollbackAD(hdr, additionalData []byte) []byte {
+	ad := make([]byte, 0, len(hdr)+len(additionalData))
+	ad = append(ad, hdr...)
+	return append(ad, additionalData...)
+}
@@ -0,0 +1,32 @@
+package twine
+
+import "testing"
+
+func TestRollbackCounter(t *testing.T) {
+
+	b, _ := New(tests[1].key)
+	a, _ := NewEAX(b)
+	nonce := []byte("nonce-01")
+
+	blob := SealWithCounter(a, nil, nonce, 42, []byte("config"), []byte("device-7"))
+
+	pt, ctr, err := OpenWithCounter(a, nil, blob, []byte("device-7"), MinCounter(42))
+	if err != nil || string(pt) != "config" || ctr != 42 {
+		t.Fatalf("OpenWithCounter = %q, %d, %v", pt, ctr, err)
+	}
+
+	if _, ctr, err := OpenWithCounter(a, nil, blob, []byte("device-7"), MinCounter(43)); err != ErrRollback || ctr != 42 {
+		t.Errorf("replayed blob: counter %d, err = %v, want ErrRollback", ctr, err)
+	}
+
+	// raising the counter in transit breaks authentication
+	bad := append([]byte(nil), blob...)
+	bad[7]++
+	if _, _, err := OpenWithCounter(a, nil, bad, []byte("device-7"), nil); err != ErrAuthFailed {
+		t.Errorf("modified counter: err = %v, want ErrAuthFailed", err)
+	}
+
+	if _, _, err := OpenWithCounter(a, nil, []byte("short"), nil, nil); err != ErrMalformedContainer {
+		t.Errorf("short blob: err = %v, want ErrMalformedContainer", err)
+	}
+}
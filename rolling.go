@@ -0,0 +1,141 @@
+package twine
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"sync"
+)
+
+// rollingCode returns the 32-bit code for counter: the CMAC of the
+// big-endian counter, truncated
+func rollingCode(b cipher.Block, counter uint64) uint32 {
+	var c [8]byte
+	binary.BigEndian.PutUint64(c[:], counter)
+	m := NewCMAC(b)
+	m.Write(c[:])
+	return binary.BigEndian.Uint32(m.Sum(nil))
+}
+
+// RollingCode generates the codes of a keyfob-style transmitter: each press
+// advances a counter and sends a 32-bit truncated CMAC of it.  The caller
+// must persist Counter after each code so that codes never repeat.
+type RollingCode struct {
+	b       cipher.Block
+	counter uint64
+}
+
+// NewRollingCode returns a generator whose next code is for counter+1.
+func NewRollingCode(b cipher.Block, counter uint64) *RollingCode {
+	return &RollingCode{b: b, counter: counter}
+}
+
+// Next advances the counter and returns its code.  It returns
+// ErrCounterOverflow once the counter is exhausted.
+func (r *RollingCode) Next() (uint32, error) {
+	if r.counter == ^uint64(0) {
+		return 0, ErrCounterOverflow
+	}
+	r.counter++
+	return rollingCode(r.b, r.counter), nil
+}
+
+// Counter returns the counter of the last code generated.
+func (r *RollingCode) Counter() uint64 { return r.counter }
+
+// MaxRollingResync is the largest resync window a RollingCodeReceiver
+// accepts.  The receiver keeps the code of every counter in the window, four
+// bytes each.
+const MaxRollingResync = 1 << 16
+
+// RollingCodeReceiver accepts the codes of a RollingCode transmitter.  A code
+// is accepted if it matches one of the next window counters after the last
+// accepted one, allowing for presses out of the receiver's range.  If the
+// transmitter has drifted further, up to resync counters ahead, two
+// consecutive codes are needed to resynchronise.  Codes at or behind the last
+// accepted counter are always rejected, so captured codes cannot be replayed.
+//
+// The receiver precomputes the codes it would accept, so rejecting a code
+// costs no CMACs; only accepting one computes the codes the counter moved
+// past.  A RollingCodeReceiver is safe for concurrent use.
+type RollingCodeReceiver struct {
+	b      cipher.Block
+	window uint64
+	resync uint64
+
+	mu      sync.Mutex
+	counter uint64
+	pending uint64   // counter of a resync candidate, or 0
+	codes   []uint32 // codes[i] is the code for counter+1+i
+}
+
+// NewRollingCodeReceiver returns a receiver whose last accepted counter is
+// counter.  window should be small, such as 16, since each counter in it is
+// a guess an attacker gets for free; resync may be much larger, up to
+// MaxRollingResync.
+func NewRollingCodeReceiver(b cipher.Block, counter uint64, window, resync int) *RollingCodeReceiver {
+	if window < 1 || resync < window || resync > MaxRollingResync {
+		panic("twine: invalid rolling code windows")
+	}
+	r := &RollingCodeReceiver{b: b, counter: counter, window: uint64(window), resync: uint64(resync)}
+	// one code beyond resync for the second code of a resynchronisation
+	r.codes = make([]uint32, 0, resync+1)
+	r.fill()
+	return r
+}
+
+// fill computes the codes of the counters after the last cached one, up to
+// resync+1 of them or the end of the counter space
+func (r *RollingCodeReceiver) fill() {
+	for n := uint64(len(r.codes)); n < r.resync+1 && r.counter+n+1 > r.counter; n++ {
+		r.codes = append(r.codes, rollingCode(r.b, r.counter+n+1))
+	}
+}
+
+// advance moves the last accepted counter to counter+k
+func (r *RollingCodeReceiver) advance(k uint64) {
+	r.counter += k
+	r.codes = r.codes[:copy(r.codes, r.codes[k:])]
+	r.fill()
+}
+
+// Accept reports whether code is valid, advancing the receiver's counter if
+// it is.
+func (r *RollingCodeReceiver) Accept(code uint32) bool {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// second code of a resynchronisation
+	if r.pending != 0 {
+		i := r.pending - r.counter
+		r.pending = 0
+		if i < uint64(len(r.codes)) && r.codes[i] == code {
+			r.advance(i + 1)
+			return true
+		}
+	}
+
+	for i, c := range r.codes {
+		if uint64(i) == r.resync {
+			break
+		}
+		if c != code {
+			continue
+		}
+		if uint64(i) < r.window {
+			r.advance(uint64(i) + 1)
+			return true
+		}
+		r.pending = r.counter + uint64(i) + 1
+		return false
+	}
+
+	return false
+}
+
+// Counter returns the last accepted counter, for the caller to persist.
+func (r *RollingCodeReceiver) Counter() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.counter
+}
@@ -0,0 +1,104 @@
+package twine
+
+import (
+	"crypto/cipher"
+	"testing"
+)
+
+func TestRollingCode(t *testing.T) {
+
+	b, _ := New(tests[0].key)
+	fob := NewRollingCode(b, 100)
+	rx := NewRollingCodeReceiver(b, 100, 4, 64)
+
+	next := func() uint32 {
+		c, err := fob.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	c := next()
+	if !rx.Accept(c) {
+		t.Fatalf("first code rejected")
+	}
+	if rx.Accept(c) {
+		t.Errorf("replayed code accepted")
+	}
+
+	// presses out of range, within the window
+	next()
+	next()
+	if !rx.Accept(next()) || rx.Counter() != fob.Counter() {
+		t.Errorf("code within window rejected")
+	}
+
+	// drifted beyond the window: two consecutive codes resynchronise
+	for i := 0; i < 10; i++ {
+		next()
+	}
+	if rx.Accept(next()) {
+		t.Errorf("code beyond window accepted alone")
+	}
+	if !rx.Accept(next()) || rx.Counter() != fob.Counter() {
+		t.Errorf("resynchronisation failed")
+	}
+
+	// a stale pair does not resynchronise
+	old := NewRollingCode(b, 50)
+	c1, _ := old.Next()
+	c2, _ := old.Next()
+	if rx.Accept(c1) || rx.Accept(c2) {
+		t.Errorf("old codes accepted")
+	}
+
+	// beyond the resync window, nothing helps
+	for i := 0; i < 100; i++ {
+		next()
+	}
+	if rx.Accept(next()) || rx.Accept(next()) {
+		t.Errorf("code beyond resync window accepted")
+	}
+}
+
+// countingBlock counts the blocks encrypted with it
+type countingBlock struct {
+	cipher.Block
+	n int
+}
+
+func (c *countingBlock) Encrypt(dst, src []byte) {
+	c.n++
+	c.Block.Encrypt(dst, src)
+}
+
+func TestRollingCodeCost(t *testing.T) {
+
+	b, _ := New(tests[0].key)
+	cb := &countingBlock{Block: b}
+	rx := NewRollingCodeReceiver(cb, 0, 16, MaxRollingResync)
+
+	// rejected codes compute no CMACs under the lock
+	cb.n = 0
+	for code := uint32(0); code < 1000; code++ {
+		rx.Accept(code)
+	}
+	if cb.n != 0 {
+		t.Errorf("rejecting 1000 codes encrypted %d blocks", cb.n)
+	}
+
+	mustPanic(t, "resync above MaxRollingResync", func() {
+		NewRollingCodeReceiver(b, 0, 16, MaxRollingResync+1)
+	})
+
+	// the look-ahead stops at the end of the counter space
+	fob := NewRollingCode(b, ^uint64(0)-3)
+	rx = NewRollingCodeReceiver(b, ^uint64(0)-3, 2, 8)
+	for i := 0; i < 3; i++ {
+		c, _ := fob.Next()
+		if !rx.Accept(c) || rx.Counter() != fob.Counter() {
+			t.Fatalf("code %d before the end of the counter space rejected", i)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package twine
+
+import (
+	"crypto/cipher"
+	"io"
+	"runtime"
+)
+
+// Wipe overwrites b with zeros.  Use it to scrub key material once it is no
+// longer needed.
+func Wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+	runtime.KeepAlive(b)
+}
+
+// Wiper is implemented by ciphers, MACs and AEADs that can scrub their key
+// material from memory.  The ciphers returned by New implement it, as do
+// the modes built on them.
+type Wiper interface {
+	// Wipe overwrites the key material the value owns.  A mode wipes its
+	// own subkeys and buffers but not the cipher it was given, which the
+	// caller may share and must wipe itself.  The value must not be used
+	// afterwards: any further use panics.
+	Wipe()
+}
+
+const (
+	errWiped     = "twine: use of wiped cipher"
+	errWipedMode = "twine: use of wiped MAC or AEAD"
+)
+
+// Wipe scrubs the round keys.
+func (t *twineCipher) Wipe() {
+	t.rk = [36][8]byte{}
+	t.drk = [36][8]byte{}
+	t.rkp = [36]uint64{}
+	t.drkp = [36]uint64{}
+	t.wiped = true
+	runtime.KeepAlive(t)
+}
+
+// WithSecret reads n bytes from r into a scratch buffer, calls fn with it and
+// wipes the buffer before returning, whether or not fn succeeds.  fn must not
+// retain the slice.
+func WithSecret(r io.Reader, n int, fn func(secret []byte) error) error {
+	secret := make([]byte, n)
+	defer Wipe(secret)
+
+	if _, err := io.ReadFull(r, secret); err != nil {
+		return err
+	}
+
+	return fn(secret)
+}
+
+// NewFromReader reads a size byte key from r and returns a cipher.Block
+// keyed with it, as New does.  The only copy of the raw key is wiped before
+// NewFromReader returns.
+func NewFromReader(r io.Reader, size int) (cipher.Block, error) {
+
+	if size != 10 && size != 16 {
+		return nil, KeySizeError(size)
+	}
+
+	var b cipher.Block
+	err := WithSecret(r, size, func(key []byte) error {
+		var err error
+		b, err = New(key)
+		return err
+	})
+
+	return b, err
+}
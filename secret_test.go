@@ -0,0 +1,146 @@
+package twine
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"errors"
+	"hash"
+	"testing"
+)
+
+func TestWithSecret(t *testing.T) {
+
+	var kept []byte
+	err := WithSecret(bytes.NewReader([]byte("secret key")), 10, func(s []byte) error {
+		if string(s) != "secret key" {
+			t.Errorf("got secret %q", s)
+		}
+		kept = s
+		return errors.New("fail")
+	})
+
+	if err == nil || err.Error() != "fail" {
+		t.Errorf("WithSecret error=%v, want fn's error", err)
+	}
+
+	if !bytes.Equal(kept, make([]byte, 10)) {
+		t.Errorf("secret not wiped: % 02x", kept)
+	}
+
+	if err := WithSecret(bytes.NewReader([]byte("short")), 10, func([]byte) error { return nil }); err == nil {
+		t.Error("expected error for short reader")
+	}
+}
+
+func TestNewFromReader(t *testing.T) {
+
+	for _, tst := range tests {
+
+		c, err := NewFromReader(bytes.NewReader(tst.key), len(tst.key))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var ct [8]byte
+		c.Encrypt(ct[:], tst.plain)
+		if !bytes.Equal(ct[:], tst.cipher) {
+			t.Errorf("encrypt failed:\ngot : % 02x\nwant: % 02x", ct[:], tst.cipher)
+		}
+	}
+
+	if _, err := NewFromReader(bytes.NewReader(make([]byte, 12)), 12); err == nil {
+		t.Error("expected KeySizeError")
+	}
+}
+
+func TestWiper(t *testing.T) {
+
+	for _, opts := range [][]Option{nil, {WithTables()}, {WithOnTheFlyKeySchedule()}} {
+		b, _ := New(tests[1].key, opts...)
+		b.(Wiper).Wipe()
+
+		if tw, ok := b.(*twineCipher); ok {
+			if tw.rk != [36][8]byte{} || tw.drk != [36][8]byte{} || tw.rkp != [36]uint64{} || tw.drkp != [36]uint64{} {
+				t.Errorf("round keys not wiped")
+			}
+		}
+		if tl, ok := b.(*twineLite); ok {
+			if tl.start.wk != [32]byte{} || tl.end.wk != [32]byte{} {
+				t.Errorf("key state not wiped")
+			}
+		}
+
+		mustPanic(t, "Encrypt after Wipe", func() { b.Encrypt(make([]byte, 8), make([]byte, 8)) })
+		mustPanic(t, "Decrypt after Wipe", func() { b.Decrypt(make([]byte, 8), make([]byte, 8)) })
+		if mb, ok := b.(MultiBlock); ok {
+			mustPanic(t, "EncryptBlocks after Wipe", func() { mb.EncryptBlocks(make([]byte, 1024), make([]byte, 1024)) })
+		}
+	}
+}
+
+// plainBlock hides the Wiper of a cipher
+type plainBlock struct{ cipher.Block }
+
+func TestModeWipe(t *testing.T) {
+
+	for _, wrap := range []bool{false, true} {
+		b, _ := New(tests[1].key)
+		b2, _ := New(tests[0].key)
+		shared := b
+		if wrap {
+			shared = plainBlock{b}
+		}
+
+		eax, _ := NewEAX(shared)
+		ccm, _ := NewCCM(shared, 4, 8)
+		siv, _ := NewSIV(shared, b2, 0)
+		lrw, _ := NewLRW(shared, make([]byte, 8))
+		xex := NewXEX(shared)
+		macs := map[string]hash.Hash{
+			"CMAC":     NewCMAC(shared),
+			"CBC-MAC":  NewCBCMAC(shared),
+			"EMAC":     NewEMAC(shared, b2),
+			"PMAC":     NewPMAC(shared),
+			"LightMAC": NewLightMAC(shared, b2, 2),
+		}
+
+		for name, w := range map[string]Wiper{"EAX": eax.(Wiper), "CCM": ccm.(Wiper), "SIV": siv.(Wiper), "LRW": lrw, "XEX": xex} {
+			w.Wipe()
+			if b.(*twineCipher).wiped {
+				t.Fatalf("%s: Wipe wiped the caller's cipher", name)
+			}
+		}
+		for name, m := range macs {
+			m.(Wiper).Wipe()
+			if b.(*twineCipher).wiped {
+				t.Fatalf("%s: Wipe wiped the caller's cipher", name)
+			}
+			mustPanic(t, name+" Write after Wipe", func() { m.Write([]byte("x")) })
+			mustPanic(t, name+" Sum after Wipe", func() { m.Sum(nil) })
+			mustPanic(t, name+" Reset after Wipe", func() { m.Reset() })
+		}
+
+		mustPanic(t, "EAX Open after Wipe", func() { eax.Open(nil, make([]byte, 8), make([]byte, 8), nil) })
+		mustPanic(t, "CCM Open after Wipe", func() { ccm.Open(nil, make([]byte, 4), make([]byte, 8), nil) })
+		mustPanic(t, "SIV Open after Wipe", func() { siv.Open(nil, nil, make([]byte, 8), nil) })
+		mustPanic(t, "LRW Decrypt after Wipe", func() { lrw.Decrypt(make([]byte, 8), make([]byte, 8), 1) })
+		mustPanic(t, "XEX Decrypt after Wipe", func() { xex.Decrypt(make([]byte, 8), make([]byte, 8), 1) })
+
+		// the shared cipher still works for its other users
+		var ct [8]byte
+		shared.Encrypt(ct[:], tests[1].plain)
+		if !bytes.Equal(ct[:], tests[1].cipher) {
+			t.Errorf("shared cipher broken by wiping its modes")
+		}
+	}
+}
+
+func mustPanic(t *testing.T, name string, fn func()) {
+	t.Helper()
+	defer func() {
+		if recover() == nil {
+			t.Errorf("%s did not panic", name)
+		}
+	}()
+	fn()
+}
@@ -0,0 +1,96 @@
+package twine
+
+import "fmt"
+
+// The tables in twine.go are checked against their defining properties by
+// verifytables.go.  Everything derived from them at run time is recomputed
+// here, independently of the code that derives it, and compared entry by
+// entry, so a bad edit to a table or to the code deriving from it stops the
+// package rather than producing wrong ciphertext.
+
+func init() {
+	if err := checkDerived(); err != nil {
+		panic(err)
+	}
+}
+
+// checkDerived compares the S-box copies and evaluations, the shuffles and
+// the round constants with their definitions
+func checkDerived() error {
+
+	inv := InvSBox()
+	for x, y := range sbox {
+		if sboxArr[x] != y {
+			return fmt.Errorf("twine: sboxArr[%#x] = %#x, want %#x", x, sboxArr[x], y)
+		}
+		if got := sboxCT(byte(x)); got != y {
+			return fmt.Errorf("twine: constant-time S(%#x) = %#x, want %#x", x, got, y)
+		}
+		if inv[y] != byte(x) {
+			return fmt.Errorf("twine: S^-1(%#x) = %#x, want %#x", y, inv[y], x)
+		}
+	}
+
+	for h, v := range shuf {
+		if shufinv[v] != h {
+			return fmt.Errorf("twine: shufinv[%d] = %d, want %d", v, shufinv[v], h)
+		}
+		if got, want := shuffle(0xf<<(60-4*uint(h))), uint64(0xf)<<(60-4*uint(v)); got != want {
+			return fmt.Errorf("twine: shuffle moves nybble %d to %#016x, want %#016x", h, got, want)
+		}
+		if got, want := unshuffle(0xf<<(60-4*uint(v))), uint64(0xf)<<(60-4*uint(h)); got != want {
+			return fmt.Errorf("twine: unshuffle moves nybble %d to %#016x, want %#016x", v, got, want)
+		}
+	}
+
+	// CON^i is x^(i-1) in GF(2)[x]/(x^6 + x + 1)
+	for i, c := range roundconst {
+		if want := gf6Pow(i); c != want {
+			return fmt.Errorf("twine: CON^%d = %#x, want %#x", i+1, c, want)
+		}
+	}
+
+	return nil
+}
+
+// gf6Pow returns x^n in GF(2)[x]/(x^6 + x + 1) by square-and-multiply
+func gf6Pow(n int) byte {
+	mul := func(a, b byte) byte {
+		var p uint
+		for i := uint(0); i < 6; i++ {
+			if b>>i&1 != 0 {
+				p ^= uint(a) << i
+			}
+		}
+		for i := uint(10); i >= 6; i-- {
+			if p>>i&1 != 0 {
+				p ^= 0x43 << (i - 6)
+			}
+		}
+		return byte(p)
+	}
+
+	r, x := byte(1), byte(2)
+	for ; n > 0; n >>= 1 {
+		if n&1 != 0 {
+			r = mul(r, x)
+		}
+		x = mul(x, x)
+	}
+	return r
+}
+
+// checkTTables compares each entry of tab, built with the shuffle perm, with
+// the S-box outputs for its input placed directly at their shuffled nybbles
+func checkTTables(tab *tTables, perm []int) error {
+	for k := range tab {
+		for v := range tab[k] {
+			want := uint64(sboxCT(byte(v>>4)))<<(60-4*uint(perm[4*k+1])) |
+				uint64(sboxCT(byte(v&0x0f)))<<(60-4*uint(perm[4*k+3]))
+			if tab[k][v] != want {
+				return fmt.Errorf("twine: T-table %d entry %#02x = %#016x, want %#016x", k, v, tab[k][v], want)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package twine
+
+import "testing"
+
+func TestCheckDerived(t *testing.T) {
+
+	if err := checkDerived(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, want := range []byte{0x01, 0x02, 0x04, 0x08, 0x10, 0x20, 0x03, 0x06} {
+		if got := gf6Pow(i); got != roundconst[i] || got != want {
+			t.Errorf("x^%d = %#x, roundconst %#x, want %#x", i, got, roundconst[i], want)
+		}
+	}
+
+	defer func(c byte) { roundconst[20] = c }(roundconst[20])
+	roundconst[20] ^= 1
+	if checkDerived() == nil {
+		t.Error("corrupted round constant not detected")
+	}
+}
+
+func TestCheckTTables(t *testing.T) {
+
+	tablesOnce.Do(initTables)
+	if err := checkTTables(encTab, shuf); err != nil {
+		t.Error(err)
+	}
+	if err := checkTTables(decTab, shufinv); err != nil {
+		t.Error(err)
+	}
+
+	bad := *encTab
+	bad[2][0x5a] ^= 1 << 20
+	if checkTTables(&bad, shuf) == nil {
+		t.Error("corrupted T-table entry not detected")
+	}
+	if checkTTables(encTab, shufinv) == nil {
+		t.Error("encryption tables accepted with the inverse shuffle")
+	}
+}
@@ -0,0 +1,144 @@
+package twine
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/bits"
+)
+
+// number of Feistel rounds used by the small-domain permutation
+const shuffleRounds = 10
+
+// DatasetShuffler is a keyed permutation of the indices [0,n).  It is a
+// balanced Feistel network with TWINE as the round function, restricted to
+// the domain with cycle-walking, so no permutation array is ever stored.  A
+// DatasetShuffler is safe for concurrent use.
+type DatasetShuffler struct {
+	b     cipher.Block
+	n     uint64
+	half  uint
+	mask  uint64
+	tweak uint64
+}
+
+// NewDatasetShuffler returns a DatasetShuffler permuting [0,n) under key.
+// The key argument should be 10 or 16 bytes.
+func NewDatasetShuffler(key []byte, n uint64) (*DatasetShuffler, error) {
+
+	if n == 0 {
+		return nil, errors.New("twine: empty shuffle domain")
+	}
+
+	b, err := New(key)
+	if err != nil {
+		return nil, err
+	}
+
+	half := uint(bits.Len64(n-1)+1) / 2
+	if half == 0 {
+		half = 1
+	}
+
+	s := &DatasetShuffler{
+		b:    b,
+		n:    n,
+		half: half,
+		mask: 1<<half - 1,
+	}
+
+	// bind the round function to the domain size
+	s.tweak = s.encrypt(n)
+
+	return s, nil
+}
+
+// NewDatasetShufflerFromReader reads a size byte key from r and returns a
+// DatasetShuffler permuting [0,n) under it.  As with NewFromReader, the raw
+// key is wiped before it returns.
+func NewDatasetShufflerFromReader(r io.Reader, size int, n uint64) (*DatasetShuffler, error) {
+
+	if size != 10 && size != 16 {
+		return nil, KeySizeError(size)
+	}
+
+	var s *DatasetShuffler
+	err := WithSecret(r, size, func(key []byte) error {
+		var err error
+		s, err = NewDatasetShuffler(key, n)
+		return err
+	})
+
+	return s, err
+}
+
+// Len returns the size of the permuted domain.
+func (s *DatasetShuffler) Len() uint64 { return s.n }
+
+// Index returns the index at position i of the shuffled order.  It panics if
+// i is not in [0,n).
+func (s *DatasetShuffler) Index(i uint64) uint64 {
+	if i >= s.n {
+		panic("twine: shuffle position out of range")
+	}
+
+	for {
+		i = s.permute(i)
+		if i < s.n {
+			return i
+		}
+	}
+}
+
+// Position returns the position of index j in the shuffled order; it is the
+// inverse of Index.  It panics if j is not in [0,n).
+func (s *DatasetShuffler) Position(j uint64) uint64 {
+	if j >= s.n {
+		panic("twine: shuffle index out of range")
+	}
+
+	for {
+		j = s.unpermute(j)
+		if j < s.n {
+			return j
+		}
+	}
+}
+
+// Walk calls fn with each position and its index in shuffled order, starting
+// at position from, until fn returns false or the domain is exhausted.
+func (s *DatasetShuffler) Walk(from uint64, fn func(pos, idx uint64) bool) {
+	for i := from; i < s.n; i++ {
+		if !fn(i, s.Index(i)) {
+			return
+		}
+	}
+}
+
+func (s *DatasetShuffler) permute(x uint64) uint64 {
+	l, r := x>>s.half, x&s.mask
+	for i := 0; i < shuffleRounds; i++ {
+		l, r = r, l^s.round(i, r)
+	}
+	return l<<s.half | r
+}
+
+func (s *DatasetShuffler) unpermute(x uint64) uint64 {
+	l, r := x>>s.half, x&s.mask
+	for i := shuffleRounds - 1; i >= 0; i-- {
+		l, r = r^s.round(i, l), l
+	}
+	return l<<s.half | r
+}
+
+func (s *DatasetShuffler) round(i int, r uint64) uint64 {
+	return s.encrypt(s.tweak^(uint64(i)<<56|r)) & s.mask
+}
+
+func (s *DatasetShuffler) encrypt(x uint64) uint64 {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], x)
+	s.b.Encrypt(b[:], b[:])
+	return binary.BigEndian.Uint64(b[:])
+}
@@ -0,0 +1,66 @@
+package twine
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDatasetShuffler(t *testing.T) {
+
+	key := []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x99}
+
+	for _, n := range []uint64{1, 2, 3, 10, 255, 256, 1000} {
+
+		s, err := NewDatasetShuffler(key, n)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		seen := make([]bool, n)
+		s.Walk(0, func(pos, idx uint64) bool {
+			if idx >= n {
+				t.Fatalf("n=%d: index %d out of range", n, idx)
+			}
+			if seen[idx] {
+				t.Fatalf("n=%d: index %d repeated", n, idx)
+			}
+			seen[idx] = true
+
+			if p := s.Position(idx); p != pos {
+				t.Errorf("n=%d: Position(%d)=%d, want %d", n, idx, p, pos)
+			}
+			return true
+		})
+
+		s2, _ := NewDatasetShuffler(key, n)
+		for i := uint64(0); i < n; i++ {
+			if s.Index(i) != s2.Index(i) {
+				t.Fatalf("n=%d: shuffle not deterministic at %d", n, i)
+			}
+		}
+	}
+
+	if _, err := NewDatasetShuffler(key, 0); err == nil {
+		t.Error("expected error for empty domain")
+	}
+}
+
+func TestNewDatasetShufflerFromReader(t *testing.T) {
+
+	key := tests[0].key
+	want, _ := NewDatasetShuffler(key, 1000)
+	s, err := NewDatasetShufflerFromReader(bytes.NewReader(key), len(key), 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := uint64(0); i < 1000; i++ {
+		if s.Index(i) != want.Index(i) {
+			t.Fatalf("Index(%d) = %d, want %d", i, s.Index(i), want.Index(i))
+		}
+	}
+
+	if _, err := NewDatasetShufflerFromReader(bytes.NewReader(make([]byte, 12)), 12, 1000); err == nil {
+		t.Error("expected KeySizeError")
+	}
+}
@@ -0,0 +1,161 @@
+package twine
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"errors"
+)
+
+// siv implements SIV mode (RFC 5297): S2V, a vector MAC built from CMAC,
+// computes a synthetic IV from the associated data, nonce and plaintext,
+// and the IV is then used as the counter of CTR mode under a second key
+type siv struct {
+	enc       cipher.Block
+	mac       cmac // template, copied for each S2V computation
+	bs        int
+	nonceSize int
+	wiped     bool
+}
+
+// NewSIV returns an AEAD in SIV mode, authenticating with CMAC under mac
+// and encrypting in CTR mode under enc.  The two ciphers must have 64-bit
+// blocks and independent keys.
+//
+// SIV is resistant to nonce misuse: a repeated nonce reveals only whether
+// the same plaintext and associated data were sealed again, and nothing
+// else.  With nonceSize 0 the mode is deterministic and Seal takes no nonce;
+// otherwise the nonce, of nonceSize bytes, is the last S2V input before the
+// plaintext, as in RFC 5297 section 3, and need only be unique on a
+// best-effort basis.  The associated data is always a single S2V input,
+// even when empty.
+//
+// Sealed messages are the 8-byte synthetic IV followed by the ciphertext,
+// so Overhead is 8 but the IV comes first.  As with any mode over a 64-bit
+// block, rekey well before 2^32 messages.  The AEAD also implements Wiper.
+func NewSIV(mac, enc cipher.Block, nonceSize int) (cipher.AEAD, error) {
+	if mac.BlockSize() != 8 || enc.BlockSize() != 8 {
+		return nil, errors.New("twine: SIV requires 64-bit block ciphers")
+	}
+	if nonceSize < 0 {
+		return nil, errors.New("twine: invalid SIV nonce size")
+	}
+	return newSIV(mac, enc, nonceSize), nil
+}
+
+// newSIV is NewSIV for any block size, so the S2V and counter logic can be
+// checked against the AES vectors of RFC 5297
+func newSIV(mac, enc cipher.Block, nonceSize int) *siv {
+	return &siv{enc: enc, mac: *newCMAC(mac), bs: mac.BlockSize(), nonceSize: nonceSize}
+}
+
+// Wipe scrubs the CMAC subkeys.  The AEAD must not be used afterwards: Seal
+// and Open panic.
+func (s *siv) Wipe() {
+	s.mac.Wipe()
+	s.wiped = true
+}
+
+func (s *siv) NonceSize() int { return s.nonceSize }
+
+func (s *siv) Overhead() int { return s.bs }
+
+// cmac returns the CMAC of p
+func (s *siv) cmac(p []byte) []byte {
+	m := s.mac
+	m.Write(p)
+	return m.Sum(nil)
+}
+
+// s2v computes the synthetic IV of the inputs; the last, the plaintext, is
+// treated specially
+func (s *siv) s2v(inputs ...[]byte) []byte {
+	d := s.cmac(make([]byte, s.bs))
+	for _, in := range inputs[:len(inputs)-1] {
+		d = double(d)
+		subtle.XORBytes(d, d, s.cmac(in))
+	}
+
+	last := inputs[len(inputs)-1]
+	m := s.mac
+	if len(last) >= s.bs {
+		// xorend: fold D into the final block of the input
+		n := len(last) - s.bs
+		m.Write(last[:n])
+		t := append([]byte(nil), last[n:]...)
+		subtle.XORBytes(t, t, d)
+		m.Write(t)
+	} else {
+		d = double(d)
+		d[len(last)] ^= 0x80
+		subtle.XORBytes(d, d, last)
+		m.Write(d)
+	}
+	return m.Sum(nil)
+}
+
+// ctr returns a CTR stream whose first counter block is v with the top bit
+// of each 32-bit counter word cleared, so that implementations doing 32-bit
+// adds agree with this one
+func (s *siv) ctr(v []byte) cipher.Stream {
+	q := append([]byte(nil), v...)
+	q[s.bs-4] &= 0x7f
+	if s.bs == 16 {
+		q[8] &= 0x7f
+	}
+	return cipher.NewCTR(s.enc, q)
+}
+
+func (s *siv) inputs(nonce, plaintext, additionalData []byte) [][]byte {
+	if s.nonceSize == 0 {
+		return [][]byte{additionalData, plaintext}
+	}
+	return [][]byte{additionalData, nonce, plaintext}
+}
+
+func (s *siv) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if s.wiped {
+		panic(errWipedMode)
+	}
+	if len(nonce) != s.nonceSize {
+		panic("twine: incorrect nonce length given to SIV")
+	}
+
+	v := s.s2v(s.inputs(nonce, plaintext, additionalData)...)
+
+	// plaintext may alias out shifted by the IV, so move it before writing
+	// the IV over it
+	ret, out := sliceForAppend(dst, s.bs+len(plaintext))
+	ct := out[s.bs:]
+	copy(ct, plaintext)
+	copy(out, v)
+	s.ctr(v).XORKeyStream(ct, ct)
+
+	return ret
+}
+
+func (s *siv) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if s.wiped {
+		panic(errWipedMode)
+	}
+	if len(nonce) != s.nonceSize {
+		panic("twine: incorrect nonce length given to SIV")
+	}
+	if len(ciphertext) < s.bs {
+		return nil, ErrAuthFailed
+	}
+
+	v := append([]byte(nil), ciphertext[:s.bs]...)
+	ret, out := sliceForAppend(dst, len(ciphertext)-s.bs)
+	copy(out, ciphertext[s.bs:])
+	s.ctr(v).XORKeyStream(out, out)
+
+	want := s.s2v(s.inputs(nonce, out, additionalData)...)
+	if subtle.ConstantTimeCompare(want, v) != 1 {
+		for i := range out {
+			out[i] = 0
+		}
+		return nil, ErrAuthFailed
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,72 @@
+package twine
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/hex"
+	"testing"
+)
+
+// TestSIVAES checks S2V and the counter against the deterministic AES-SIV
+// example of RFC 5297 appendix A.1.
+func TestSIVAES(t *testing.T) {
+
+	mac, _ := aes.NewCipher(unhex("fffefdfcfbfaf9f8f7f6f5f4f3f2f1f0"))
+	enc, _ := aes.NewCipher(unhex("f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff"))
+	s := newSIV(mac, enc, 0)
+
+	ad := unhex("101112131415161718191a1b1c1d1e1f2021222324252627")
+	pt := unhex("112233445566778899aabbccddee")
+	want := "85632d07c6e8f37f950acd320a2ecc9340c02b9690c4dc04daef7f6afe5c"
+
+	ct := s.Seal(nil, nil, pt, ad)
+	if got := hex.EncodeToString(ct); got != want {
+		t.Errorf("Seal = %s, want %s", got, want)
+	}
+	if got, err := s.Open(nil, nil, ct, ad); err != nil || !bytes.Equal(got, pt) {
+		t.Errorf("Open = %x, %v", got, err)
+	}
+}
+
+func TestSIV(t *testing.T) {
+
+	mac, _ := New(tests[0].key)
+	enc, _ := New(tests[1].key)
+
+	for _, nonceSize := range []int{0, 8} {
+		a, err := NewSIV(mac, enc, nonceSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		testAEAD(t, "SIV", a, nil)
+	}
+
+	// deterministic: equal inputs seal identically, any change differs
+	a, _ := NewSIV(mac, enc, 0)
+	c1 := a.Seal(nil, nil, []byte("open valve 3"), []byte("dev 7"))
+	c2 := a.Seal(nil, nil, []byte("open valve 3"), []byte("dev 7"))
+	c3 := a.Seal(nil, nil, []byte("open valve 4"), []byte("dev 7"))
+	if !bytes.Equal(c1, c2) || bytes.Equal(c1[:8], c3[:8]) {
+		t.Errorf("SIV not deterministic: %x %x %x", c1, c2, c3)
+	}
+
+	// a failed Open leaves no plaintext behind
+	c1[len(c1)-1] ^= 1
+	dst := make([]byte, 0, len(c1))
+	if _, err := a.Open(dst, nil, c1, []byte("dev 7")); err != ErrAuthFailed {
+		t.Errorf("tampered Open: %v", err)
+	}
+	if !bytes.Equal(dst[:len(c1)-8], make([]byte, len(c1)-8)) {
+		t.Errorf("plaintext of failed Open not cleared")
+	}
+
+	if _, err := NewSIV(mac, enc, -1); err == nil {
+		t.Errorf("negative nonce size accepted")
+	}
+
+	a.(Wiper).Wipe()
+	if enc.(*twineCipher).wiped || mac.(*twineCipher).wiped {
+		t.Errorf("caller's ciphers wiped with the AEAD")
+	}
+	mustPanic(t, "SIV Seal after Wipe", func() { a.Seal(nil, nil, nil, nil) })
+}
@@ -0,0 +1,44 @@
+package twine
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTables(t *testing.T) {
+
+	for _, tt := range tests {
+		b, err := New(tt.key, WithTables())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !b.(*twineCipher).tables {
+			t.Fatalf("WithTables ignored")
+		}
+
+		var ct, pt [8]byte
+		b.Encrypt(ct[:], tt.plain)
+		if !bytes.Equal(ct[:], tt.cipher) {
+			t.Errorf("%d-bit key: Encrypt = %x, want %x", 8*len(tt.key), ct, tt.cipher)
+		}
+		b.Decrypt(pt[:], ct[:])
+		if !bytes.Equal(pt[:], tt.plain) {
+			t.Errorf("%d-bit key: Decrypt = %x, want %x", 8*len(tt.key), pt, tt.plain)
+		}
+	}
+
+	// constant time wins over tables
+	b, _ := New(tests[0].key, WithTables(), WithConstantTime())
+	if b.(*twineCipher).tables {
+		t.Errorf("WithTables used alongside WithConstantTime")
+	}
+}
+
+func BenchmarkEncryptTables(b *testing.B) {
+	c, _ := New(tests[1].key, WithTables())
+	var blk [8]byte
+	b.SetBytes(8)
+	for i := 0; i < b.N; i++ {
+		c.Encrypt(blk[:], blk[:])
+	}
+}
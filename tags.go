@@ -0,0 +1,118 @@
+package twine
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"errors"
+	"sync/atomic"
+)
+
+// MinTagSize is the shortest tag the MAC and AEAD constructors accept by
+// default: a full 64-bit block.
+const MinTagSize = 8
+
+// minShortTagSize is the shortest tag accepted with AllowShortTags
+const minShortTagSize = 4
+
+// A TagOption relaxes the tag length policy of a MAC or AEAD constructor.
+type TagOption func(*tagPolicy)
+
+type tagPolicy struct {
+	allowShort bool
+}
+
+// AllowShortTags lets a constructor accept tags of 4 to 7 bytes, for
+// bandwidth-starved links.  A t-byte tag is forged with probability 2^-8t
+// per attempt, so a verifier with short tags accepts at most 2^(8t-10)
+// messages under one key, keeping the chance of any forgery below 2^-10,
+// and then returns ErrKeyExhausted: 2^22 messages with 4-byte tags, as NIST
+// SP 800-38D allows for 32-bit GCM tags.  The key must then be replaced.
+func AllowShortTags() TagOption {
+	return func(p *tagPolicy) { p.allowShort = true }
+}
+
+var errTagSize = errors.New("twine: invalid tag size")
+
+// checkTagSize reports whether size is an acceptable tag size under opts,
+// for a MAC with max-byte tags
+func checkTagSize(size, max int, opts []TagOption) error {
+	var p tagPolicy
+	for _, o := range opts {
+		o(&p)
+	}
+	min := MinTagSize
+	if p.allowShort {
+		min = minShortTagSize
+	}
+	if size < min || size > max {
+		return errTagSize
+	}
+	return nil
+}
+
+// tagBudget counts the verifications made under one key against the limit
+// for its tag size
+type tagBudget struct {
+	limit uint64 // 0 for no limit
+	used  atomic.Uint64
+}
+
+// tagLimit returns the number of verifications allowed under one key for
+// size-byte tags, or 0 for no limit
+func tagLimit(size int) uint64 {
+	if size < MinTagSize {
+		return 1 << uint(8*size-10)
+	}
+	return 0
+}
+
+// spend counts one verification, returning ErrKeyExhausted once the limit
+// has been reached
+func (b *tagBudget) spend() error {
+	if b.limit != 0 && b.used.Add(1) > b.limit {
+		return ErrKeyExhausted
+	}
+	return nil
+}
+
+// CMACVerifier checks truncated CMAC tags under one key, enforcing the
+// per-key message limit of its tag size.  VerifyCMAC keeps no state and so
+// cannot; use a CMACVerifier for short tags.  A CMACVerifier is safe for
+// concurrent use.
+type CMACVerifier struct {
+	b       cipher.Block
+	tagSize int
+	budget  tagBudget
+}
+
+// NewCMACVerifier returns a verifier for tagSize-byte CMAC tags under b,
+// which must have a 64-bit block.  Tags shorter than 8 bytes, down to 4,
+// require AllowShortTags.
+func NewCMACVerifier(b cipher.Block, tagSize int, opts ...TagOption) (*CMACVerifier, error) {
+	if b.BlockSize() != 8 {
+		return nil, errors.New("twine: CMAC requires a 64-bit block cipher")
+	}
+	if err := checkTagSize(tagSize, 8, opts); err != nil {
+		return nil, err
+	}
+	return &CMACVerifier{b: b, tagSize: tagSize, budget: tagBudget{limit: tagLimit(tagSize)}}, nil
+}
+
+// Verify checks, in constant time, that tag is the truncated CMAC of msg.
+// It returns ErrAuthFailed if it is not, and ErrKeyExhausted, without
+// checking, once the key has verified as many messages as its tag size
+// allows.
+func (v *CMACVerifier) Verify(msg, tag []byte) error {
+	if err := v.budget.spend(); err != nil {
+		return err
+	}
+	if len(tag) != v.tagSize {
+		return ErrAuthFailed
+	}
+	m := NewCMAC(v.b)
+	m.Write(msg)
+	if subtle.ConstantTimeCompare(m.Sum(nil)[:v.tagSize], tag) != 1 {
+		return ErrAuthFailed
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package twine
+
+import (
+	"crypto/cipher"
+	"testing"
+)
+
+func TestTagBudget(t *testing.T) {
+
+	for size, want := range map[int]uint64{4: 1 << 22, 6: 1 << 38, 7: 1 << 46, 8: 0} {
+		if got := tagLimit(size); got != want {
+			t.Errorf("%d-byte tags: limit %d, want %d", size, got, want)
+		}
+	}
+
+	b, _ := New(tests[1].key)
+	e, _ := NewEAXWithTagSize(b, 4, AllowShortTags())
+	c, _ := NewCCM(b, 4, 4, AllowShortTags())
+	full, _ := NewEAX(b)
+
+	e.(*eax).budget.limit = 3
+	c.(*ccm).budget.limit = 3
+	for name, a := range map[string]cipher.AEAD{"EAX": e, "CCM": c} {
+		nonce := make([]byte, a.NonceSize())
+		sealed := a.Seal(nil, nonce, []byte("frame"), nil)
+		bad := append([]byte(nil), sealed...)
+		bad[0] ^= 1
+
+		if _, err := a.Open(nil, nonce, bad, nil); err != ErrAuthFailed {
+			t.Errorf("%s: forged frame: err = %v, want ErrAuthFailed", name, err)
+		}
+		for i := 0; i < 2; i++ {
+			if _, err := a.Open(nil, nonce, sealed, nil); err != nil {
+				t.Errorf("%s: Open %d: %v", name, i, err)
+			}
+		}
+		if _, err := a.Open(nil, nonce, sealed, nil); err != ErrKeyExhausted {
+			t.Errorf("%s: Open past the limit: err = %v, want ErrKeyExhausted", name, err)
+		}
+	}
+
+	if full.(*eax).budget.limit != 0 {
+		t.Errorf("8-byte EAX tags are limited")
+	}
+}
+
+func TestCMACVerifier(t *testing.T) {
+
+	b, _ := New(tests[1].key)
+	m := NewCMAC(b)
+	m.Write([]byte("open door"))
+	tag := m.Sum(nil)
+
+	if _, err := NewCMACVerifier(b, 4); err == nil {
+		t.Error("4-byte tag accepted without AllowShortTags")
+	}
+
+	v, err := NewCMACVerifier(b, 4, AllowShortTags())
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.budget.limit = 2
+
+	if err := v.Verify([]byte("open door"), tag[:4]); err != nil {
+		t.Errorf("valid tag: %v", err)
+	}
+	if err := v.Verify([]byte("open gate"), tag[:4]); err != ErrAuthFailed {
+		t.Errorf("wrong message: err = %v, want ErrAuthFailed", err)
+	}
+	if err := v.Verify([]byte("open door"), tag[:4]); err != ErrKeyExhausted {
+		t.Errorf("past the limit: err = %v, want ErrKeyExhausted", err)
+	}
+
+	v, _ = NewCMACVerifier(b, 8)
+	if err := v.Verify([]byte("open door"), tag[:4]); err != ErrAuthFailed {
+		t.Errorf("short tag for an 8-byte verifier: err = %v, want ErrAuthFailed", err)
+	}
+}
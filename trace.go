@@ -0,0 +1,125 @@
+package twine
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Trace holds the intermediate values of a single TWINE encryption, in the
+// nybble order used by the specification, for comparing an implementation
+// against the specification round by round.
+type Trace struct {
+	// RoundKeys[i] is RK^(i+1), the round key of round i+1
+	RoundKeys [36][8]byte
+
+	// States[i] is the 16-nybble state after round i+1; the last entry is
+	// the ciphertext
+	States [36][16]byte
+}
+
+// EncryptTrace encrypts the 8-byte block src under key and returns the
+// ciphertext together with its intermediate values.  It is a slow reference
+// path, key schedule included, kept separate from New and Encrypt, for
+// debugging and tests only.  It returns an error if the key is not 10 or 16
+// bytes or src is not a single block.
+func EncryptTrace(key, src []byte) ([]byte, *Trace, error) {
+
+	if len(key) != 10 && len(key) != 16 {
+		return nil, nil, KeySizeError(len(key))
+	}
+	if len(src) != 8 {
+		return nil, nil, errors.New("twine: EncryptTrace input must be a single block")
+	}
+
+	tr := &Trace{}
+	traceKeys(key, &tr.RoundKeys)
+	rk := &tr.RoundKeys
+
+	var x [16]byte
+	for i := 0; i < 8; i++ {
+		x[2*i] = src[i] >> 4
+		x[2*i+1] = src[i] & 0x0f
+	}
+
+	for i := 0; i < 36; i++ {
+		for j := 0; j < 8; j++ {
+			x[2*j+1] ^= sbox[x[2*j]^rk[i][j]]
+		}
+
+		if i < 35 {
+			var xnext [16]byte
+			for h := 0; h < 16; h++ {
+				xnext[shuf[h]] = x[h]
+			}
+			x = xnext
+		}
+
+		tr.States[i] = x
+	}
+
+	dst := make([]byte, 8)
+	for i := 0; i < 8; i++ {
+		dst[i] = x[2*i]<<4 | x[2*i+1]
+	}
+
+	return dst, tr, nil
+}
+
+// traceKeys runs the key schedule as the specification writes it, one
+// nybble of WK at a time
+func traceKeys(key []byte, rk *[36][8]byte) {
+
+	n := 2 * len(key)
+	wk := make([]byte, n)
+	for i, v := range key {
+		wk[2*i], wk[2*i+1] = v>>4, v&0x0f
+	}
+	defer Wipe(wk)
+
+	taps := []int{1, 3, 4, 6, 13, 14, 15, 16}
+	if n == 32 {
+		taps = []int{2, 3, 12, 15, 17, 18, 28, 31}
+	}
+
+	for i := 0; ; i++ {
+		for j, tap := range taps {
+			rk[i][j] = wk[tap]
+		}
+		if i == 35 {
+			return
+		}
+
+		wk[1] ^= sbox[wk[0]]
+		wk[4] ^= sbox[wk[16]]
+		if n == 32 {
+			wk[23] ^= sbox[wk[30]]
+		}
+		wk[7] ^= roundconst[i] >> 3
+		wk[19] ^= roundconst[i] & 7
+
+		// WK0..WK3 <<< 4 bits, then the whole register <<< 16 bits
+		w := [4]byte{wk[1], wk[2], wk[3], wk[0]}
+		copy(wk, wk[4:])
+		copy(wk[n-4:], w[:])
+	}
+}
+
+// String formats the trace one round per line as the round number, the
+// round key and the state after the round, each in hex nybbles.
+func (tr *Trace) String() string {
+	var sb strings.Builder
+	for i := range tr.States {
+		fmt.Fprintf(&sb, "%2d %s %s\n", i+1, nybbles(tr.RoundKeys[i][:]), nybbles(tr.States[i][:]))
+	}
+	return sb.String()
+}
+
+func nybbles(x []byte) string {
+	const hex = "0123456789abcdef"
+	b := make([]byte, len(x))
+	for i, v := range x {
+		b[i] = hex[v]
+	}
+	return string(b)
+}
@@ -0,0 +1,109 @@
+package twine
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// traceVectors are the round keys RK^1..RK^36 and the states after each
+// round for the specification's test vectors, from a reference
+// implementation written independently from the specification's
+// description of the key schedule and round function; their final states
+// are the published ciphertexts.
+var traceVectors = []struct {
+	rks    []string
+	states []string
+}{
+	{
+		rks: []string{
+			"01236778", "2345898c", "4567112f", "678933ef", "89c15559", "10f37729",
+			"3af599c5", "559710c2", "72993aa7", "9d50550e", "1f2a7268", "3c759dcb",
+			"54e20f0e", "768dacf9", "9dbf542f", "02ec26ce", "aa94ddb4", "50f6f2c2",
+			"28edcae2", "da4240ce", "ff2a686d", "c920da50", "4be82f1d", "60daa992",
+			"d00f0bfa", "23d98012", "ad2ba00c", "0fa0f3a5", "80209d4a", "a3c3bf78",
+			"fd5d001e", "92af03cd", "b3803d13", "00e3d229", "0eddf36f", "3532006e",
+		},
+		states: []string{
+			"d25690f4caaec86c", "654ffd899aa6ec1c", "a418f62fdad1e9ae", "51721acf8d5a3d5e",
+			"f76c4531154548f3", "16f35fc4d41f21a4", "9f7c8165717a2d02", "e7f6398857b017d2",
+			"4f088ee38b0d45f1", "106e6458507f0814", "c645d1f6c7116570", "240fdccdb187cc16",
+			"102cd26db8d16b2c", "9216e18dad829bb6", "e138799e88cbaa49", "73698e575c34a84a",
+			"d62587487384e56a", "e294adb878a6278e", "39ab4efa1a68f732", "fa1f731446d321cf",
+			"61819f973d3c84f2", "c8f916b943af8368", "0f4bbca13a0624f8", "645a909bd03f5362",
+			"357916e923e63d35", "570eb3e1ce237253", "30ce057ba2750c07", "ac774390e7108ac0",
+			"37192a2431bcae98", "41e263924b09c38a", "6e59849600d8943c", "a5e9c6c8fdf3b0a9",
+			"5ecc7acc9fda4fab", "5cecd5d78d6a9914", "7e1d058db6d19829", "7c1f0f80b1df9c28",
+		},
+	},
+	{
+		rks: []string{
+			"116789ef", "3388abc0", "55adcdfa", "77c9ef79", "99e51137", "bfcb33ee",
+			"ddff55db", "f5797748", "1b35999b", "3ee4bf86", "5ad0dd09", "734ff569",
+			"909e1b71", "f08e3e7d", "de015a05", "5c617358", "bb7d9030", "e378f0fd",
+			"a502de85", "3c585c8a", "0b3cbb6c", "05f2e37d", "ef87a5d4", "ca883c3d",
+			"b7660b2b", "35780526", "5cdbef0d", "c63fca36", "b926b72c", "58233588",
+			"f8045cf2", "a036c6af", "7721b948", "5581585d", "c4fcf8b8", "65aea007",
+		},
+		states: []string{
+			"121690547a2ea8fc", "d1d59149a21f078a", "9de4ddb9a138ba50", "be9b793d63b5da1b",
+			"19837b674bc1a62d", "485661e79c42c4ca", "b52ee4d6744c29fc", "726dbb8e440f7792",
+			"b6c837ab001934d7", "ecfa7b73d13d9003", "bfb71ed763306d09", "eb9dfb01831056a6",
+			"e9d0ee0f719a2815", "9df0ee3e99317742", "ff23098e13248927", "22485fa0d27201a8",
+			"147ac20557ba0d50", "07d081acdb955580", "ad0a807849b89de5", "e0d7ba98cbdee479",
+			"6d79eeeb3dc72cee", "473e663eac7ee342", "a313f406c7940aee", "a1f04a3fe90e1c40",
+			"1f938af440645e41", "09ef212816b4e4e5", "2e620022bb2e51fe", "8662e2e0c28fabd5",
+			"c6be687e883d3c5a", "5b474cf6c3b5a843", "640f65041b34ecaa", "50f03656d3eae11e",
+			"fff585a34ec16dde", "2f3a8fe84c7d9426", "939ef2b877b2a4b9", "979ff9b379b5a9b8",
+		},
+	},
+}
+
+func TestEncryptTrace(t *testing.T) {
+
+	for i, tst := range tests {
+
+		ct, tr, err := EncryptTrace(tst.key, tst.plain)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(ct, tst.cipher) {
+			t.Errorf("trace ciphertext % 02x, want % 02x", ct, tst.cipher)
+		}
+
+		want := traceVectors[i]
+		for r := 0; r < 36; r++ {
+			if got := nybbles(tr.RoundKeys[r][:]); got != want.rks[r] {
+				t.Errorf("%d-byte key: RK^%d=%s, want %s", len(tst.key), r+1, got, want.rks[r])
+			}
+			if got := nybbles(tr.States[r][:]); got != want.states[r] {
+				t.Errorf("%d-byte key: state after round %d=%s, want %s", len(tst.key), r+1, got, want.states[r])
+			}
+		}
+
+		// the schedule New computes must agree round by round
+		b, _ := New(tst.key)
+		if rk := b.(*twineCipher).rk; rk != tr.RoundKeys {
+			for r := range rk {
+				if rk[r] != tr.RoundKeys[r] {
+					t.Errorf("%d-byte key: New's RK^%d=%s, want %s", len(tst.key), r+1, nybbles(rk[r][:]), want.rks[r])
+					break
+				}
+			}
+		}
+
+		if lines := strings.Count(tr.String(), "\n"); lines != 36 {
+			t.Errorf("String() has %d lines, want 36", lines)
+		}
+	}
+
+	if _, _, err := EncryptTrace(make([]byte, 12), tests[0].plain); err == nil {
+		t.Errorf("12-byte key accepted")
+	}
+	for _, n := range []int{0, 7, 9, 16} {
+		if _, _, err := EncryptTrace(tests[0].key, make([]byte, n)); err == nil {
+			t.Errorf("%d-byte block accepted", n)
+		}
+	}
+}
@@ -10,11 +10,21 @@ package twine
 
 import (
 	"crypto/cipher"
+	"encoding/binary"
 	"strconv"
 )
 
 type twineCipher struct {
-	rk [36][8]byte
+	rk           [36][8]byte
+	drk          [36][8]byte // rk in reverse order, for decryption
+	keySize      int
+	constantTime bool
+
+	tables bool
+	rkp    [36]uint64 // packed round keys, for the table-driven rounds
+	drkp   [36]uint64 // rkp in reverse order
+
+	wiped bool
 }
 
 type KeySizeError int
@@ -22,8 +32,11 @@ type KeySizeError int
 func (k KeySizeError) Error() string { return "twine: invalid key size " + strconv.Itoa(int(k)) }
 
 // New returns a cipher.Block implementing the TWINE block cipher.  The key
-// argument should be 10 or 16 bytes.
-func New(key []byte) (cipher.Block, error) {
+// argument should be 10 or 16 bytes.  The returned cipher keeps no state
+// between calls and is safe for concurrent use by multiple goroutines.  It
+// also implements MultiBlock and Wiper, and has a Params method describing
+// its security parameters.
+func New(key []byte, opts ...Option) (cipher.Block, error) {
 
 	l := len(key)
 
@@ -31,197 +44,285 @@ func New(key []byte) (cipher.Block, error) {
 		return nil, KeySizeError(l)
 	}
 
-	tw := &twineCipher{}
-
-	switch l {
-	case 10:
-		tw.expandKeys80(key)
-	case 16:
-		tw.expandKeys128(key)
+	o := applyOptions(opts)
+	if o.onTheFly {
+		return newLite(key, o.constantTime), nil
 	}
 
+	tw := &twineCipher{keySize: l, constantTime: o.constantTime, tables: o.tables}
+	tw.init(key)
 	return tw, nil
 
 }
 
-func (t *twineCipher) BlockSize() int { return 8 }
-
-func (t *twineCipher) Encrypt(dst, src []byte) {
+// init expands key into t, whose options have been applied
+func (t *twineCipher) init(key []byte) {
 
-	var x [16]byte // actually nybbles
+	t.expandKeys(key)
 
-	for i := 0; i < len(src); i++ {
-		x[2*i] = src[i] >> 4
-		x[2*i+1] = src[i] & 0x0f
+	if t.tables && !t.constantTime {
+		tablesOnce.Do(initTables)
+		t.packRoundKeys()
+	} else {
+		t.tables = false
 	}
+}
 
-	for i := 0; i < 35; i++ {
-		for j := 0; j < 8; j++ {
-			x[2*j+1] ^= sbox[x[2*j]^t.rk[i][j]]
-		}
-
-		var xnext [16]byte
-		for h := 0; h < 16; h++ {
-			xnext[shuf[h]] = x[h]
-		}
-		x = xnext
-	}
+// An Option configures the cipher returned by New.
+type Option func(*options)
 
-	// last round
-	i := 35
-	for j := 0; j < 8; j++ {
-		x[2*j+1] ^= sbox[x[2*j]^t.rk[i][j]]
-	}
+type options struct {
+	constantTime bool
+	tables       bool
+	onTheFly     bool
+}
 
-	for i := 0; i < 8; i++ {
-		dst[i] = x[2*i]<<4 | x[2*i+1]
+func applyOptions(opts []Option) options {
+	var o options
+	for _, f := range opts {
+		f(&o)
 	}
+	return o
 }
 
-func (t *twineCipher) Decrypt(dst, src []byte) {
+func (t *twineCipher) BlockSize() int { return 8 }
 
-	var x [16]byte // actually nybbles
+// Params describes the security parameters of a keyed TWINE instance, for
+// policy engines and configuration validators.
+type Params struct {
+	BlockSize    int // block size in bytes
+	KeySize      int // key size in bytes
+	Rounds       int
+	SecurityBits int // security level claimed by the designers
+
+	// MaxBytesPerKey is the recommended limit on data processed under
+	// one key: 2^20 blocks, the limit NIST SP 800-67 sets for 3DES.  It
+	// keeps well clear of the 2^32-block birthday bound of a 64-bit block,
+	// near which block collisions leak plaintext in most modes (Sweet32).
+	MaxBytesPerKey uint64
+
+	// RecommendedModes lists the authenticated encryption modes of this
+	// package, most preferred first.  Unauthenticated modes such as CTR
+	// need a MAC alongside them.
+	RecommendedModes []string
+}
 
-	for i := 0; i < len(src); i++ {
-		x[2*i] = src[i] >> 4
-		x[2*i+1] = src[i] & 0x0f
+// Params returns the security parameters of the cipher.
+func (t *twineCipher) Params() Params { return params(t.keySize) }
+
+func params(keySize int) Params {
+	return Params{
+		BlockSize:        8,
+		KeySize:          keySize,
+		Rounds:           36,
+		SecurityBits:     8 * keySize,
+		MaxBytesPerKey:   8 << 20,
+		RecommendedModes: []string{"SIV", "EAX", "CCM"},
 	}
+}
 
-	for i := 35; i >= 1; i-- {
-		for j := 0; j < 8; j++ {
-			x[2*j+1] ^= sbox[x[2*j]^t.rk[i][j]]
-		}
+func (t *twineCipher) Encrypt(dst, src []byte) {
 
-		var xnext [16]byte
-		for h := 0; h < 16; h++ {
-			xnext[shufinv[h]] = x[h]
-		}
-		x = xnext
+	x := binary.BigEndian.Uint64(src)
+
+	switch {
+	case t.wiped:
+		panic(errWiped)
+	case t.constantTime:
+		binary.BigEndian.PutUint64(dst, t.encryptCT(x))
+		return
+	case t.tables:
+		binary.BigEndian.PutUint64(dst, t.encryptTables(x))
+		return
 	}
 
-	// last round
-	i := 0
-	for j := 0; j < 8; j++ {
-		x[2*j+1] ^= sbox[x[2*j]^t.rk[i][j]]
+	for i := 0; i < 35; i++ {
+		x = shuffle(roundF(x, &t.rk[i]))
 	}
+	x = roundF(x, &t.rk[35])
 
-	for i := 0; i < 8; i++ {
-		dst[i] = x[2*i]<<4 | x[2*i+1]
-	}
+	binary.BigEndian.PutUint64(dst, x)
 }
 
-func (t *twineCipher) expandKeys80(key []byte) {
-
-	var wk [20]byte
+func (t *twineCipher) Decrypt(dst, src []byte) {
 
-	for i := 0; i < len(key); i++ {
-		wk[2*i] = key[i] >> 4
-		wk[2*i+1] = key[i] & 0x0f
+	x := binary.BigEndian.Uint64(src)
+
+	switch {
+	case t.wiped:
+		panic(errWiped)
+	case t.constantTime:
+		binary.BigEndian.PutUint64(dst, t.decryptCT(x))
+		return
+	case t.tables:
+		binary.BigEndian.PutUint64(dst, t.decryptTables(x))
+		return
 	}
 
 	for i := 0; i < 35; i++ {
+		x = unshuffle(roundF(x, &t.drk[i]))
+	}
+	x = roundF(x, &t.drk[35])
 
-		t.rk[i][0] = wk[1]
-		t.rk[i][1] = wk[3]
-		t.rk[i][2] = wk[4]
-		t.rk[i][3] = wk[6]
-		t.rk[i][4] = wk[13]
-		t.rk[i][5] = wk[14]
-		t.rk[i][6] = wk[15]
-		t.rk[i][7] = wk[16]
-
-		wk[1] ^= sbox[wk[0]]
-		wk[4] ^= sbox[wk[16]]
-		con := roundconst[i]
-		wk[7] ^= con >> 3
-		wk[19] ^= con & 7
-
-		tmp0, tmp1, tmp2, tmp3 := wk[0], wk[1], wk[2], wk[3]
-		// TODO(dgryski): replace with copy()?
-		for j := 0; j < 4; j++ {
-			fourj := j * 4
-			wk[fourj] = wk[fourj+4]
-			wk[fourj+1] = wk[fourj+5]
-			wk[fourj+2] = wk[fourj+6]
-			wk[fourj+3] = wk[fourj+7]
-		}
-		wk[16] = tmp1
-		wk[17] = tmp2
-		wk[18] = tmp3
-		wk[19] = tmp0
+	binary.BigEndian.PutUint64(dst, x)
+}
+
+// The state is kept as 16 nybbles packed into a uint64, nybble 0 in the
+// most significant position, which is the big-endian reading of the block.
+
+// roundF applies the eight F functions of a round: each odd nybble is xored
+// with the S-box of the preceding even nybble and its round key nybble.
+func roundF(x uint64, rk *[8]byte) uint64 {
+	var y uint64
+	for j := uint(0); j < 8; j++ {
+		v := (byte(x>>(60-8*j)) ^ rk[j]) & 0x0f
+		y |= uint64(sboxArr[v]) << (56 - 8*j)
 	}
+	return x ^ y
+}
 
-	t.rk[35][0] = wk[1]
-	t.rk[35][1] = wk[3]
-	t.rk[35][2] = wk[4]
-	t.rk[35][3] = wk[6]
-	t.rk[35][4] = wk[13]
-	t.rk[35][5] = wk[14]
-	t.rk[35][6] = wk[15]
-	t.rk[35][7] = wk[16]
+// shuffle applies the block shuffle π, moving the nybbles that share a
+// displacement together.  The masks are derived from shuf.
+func shuffle(x uint64) uint64 {
+	return x&0x00000000000f0000<<36 |
+		x&0x000000f00f000ff0<<12 |
+		x&0x0ff0000000f0000f<<4 |
+		x&0x000f000f00000000>>4 |
+		x&0x0000f0000000f000>>12 |
+		x&0xf0000000f0000000>>20 |
+		x&0x00000f0000000000>>28
+}
 
+// unshuffle applies π^-1
+func unshuffle(x uint64) uint64 {
+	return x&0x000000000000f000<<28 |
+		x&0x00000f0000000f00<<20 |
+		x&0x0000000f0000000f<<12 |
+		x&0x0000f000f0000000<<4 |
+		x&0xff0000000f0000f0>>4 |
+		x&0x000f00f000ff0000>>12 |
+		x&0x00f0000000000000>>36
 }
 
-func (t *twineCipher) expandKeys128(key []byte) {
+// MultiBlock is implemented by ciphers that can process a buffer of many
+// blocks in a single call.  The cipher.Block returned by New implements it.
+type MultiBlock interface {
+	cipher.Block
 
-	var wk [32]byte
+	// EncryptBlocks encrypts the whole blocks in src into dst.  The
+	// length of src must be a multiple of the block size, and dst must be
+	// at least as long as src.  dst and src may overlap entirely.
+	EncryptBlocks(dst, src []byte)
 
-	for i := 0; i < len(key); i++ {
-		wk[2*i] = key[i] >> 4
-		wk[2*i+1] = key[i] & 0x0f
+	// DecryptBlocks is the inverse of EncryptBlocks.
+	DecryptBlocks(dst, src []byte)
+}
+
+// EncryptBlocks uses the assembly backend where available, then the
+// bitsliced implementation for each run of 64 blocks and the nybble
+// implementation for the rest.
+func (t *twineCipher) EncryptBlocks(dst, src []byte) {
+	t.checkBlocks(dst, src)
+	n := t.encryptBlocksAsm(dst, src)
+	dst, src = dst[n:], src[n:]
+	for len(src) >= 8*bitsliceBlocks {
+		t.encrypt64(dst, src)
+		dst, src = dst[8*bitsliceBlocks:], src[8*bitsliceBlocks:]
+	}
+	for i := 0; i < len(src); i += 8 {
+		t.Encrypt(dst[i:i+8], src[i:i+8])
 	}
+}
 
-	for i := 0; i < 35; i++ {
+func (t *twineCipher) DecryptBlocks(dst, src []byte) {
+	t.checkBlocks(dst, src)
+	n := t.decryptBlocksAsm(dst, src)
+	dst, src = dst[n:], src[n:]
+	for len(src) >= 8*bitsliceBlocks {
+		t.decrypt64(dst, src)
+		dst, src = dst[8*bitsliceBlocks:], src[8*bitsliceBlocks:]
+	}
+	for i := 0; i < len(src); i += 8 {
+		t.Decrypt(dst[i:i+8], src[i:i+8])
+	}
+}
 
-		t.rk[i][0] = wk[2]
-		t.rk[i][1] = wk[3]
-		t.rk[i][2] = wk[12]
-		t.rk[i][3] = wk[15]
-		t.rk[i][4] = wk[17]
-		t.rk[i][5] = wk[18]
-		t.rk[i][6] = wk[28]
-		t.rk[i][7] = wk[31]
-
-		wk[1] ^= sbox[wk[0]]
-		wk[4] ^= sbox[wk[16]]
-		wk[23] ^= sbox[wk[30]]
-		con := roundconst[i]
-		wk[7] ^= con >> 3
-		wk[19] ^= con & 7
-
-		tmp0, tmp1, tmp2, tmp3 := wk[0], wk[1], wk[2], wk[3]
-		// TODO(dgryski): replace with copy()?
-		for j := 0; j < 7; j++ {
-			fourj := j * 4
-			wk[fourj] = wk[fourj+4]
-			wk[fourj+1] = wk[fourj+5]
-			wk[fourj+2] = wk[fourj+6]
-			wk[fourj+3] = wk[fourj+7]
-		}
-		wk[28] = tmp1
-		wk[29] = tmp2
-		wk[30] = tmp3
-		wk[31] = tmp0
+func (t *twineCipher) checkBlocks(dst, src []byte) {
+	if t.wiped {
+		panic(errWiped)
+	}
+	if len(src)%8 != 0 {
+		panic("twine: input not full blocks")
+	}
+	if len(dst) < len(src) {
+		panic("twine: output smaller than input")
 	}
-	t.rk[35][0] = wk[2]
-	t.rk[35][1] = wk[3]
-	t.rk[35][2] = wk[12]
-	t.rk[35][3] = wk[15]
-	t.rk[35][4] = wk[17]
-	t.rk[35][5] = wk[18]
-	t.rk[35][6] = wk[28]
-	t.rk[35][7] = wk[31]
 }
 
+//go:generate go run verifytables.go
+
 // table 1
 var sbox = []byte{0x0C, 0x00, 0x0F, 0x0A, 0x02, 0x0B, 0x09, 0x05, 0x08, 0x03, 0x0D, 0x07, 0x01, 0x0E, 0x06, 0x04}
 
+// sboxArr is sbox as an array, so masked indexes need no bounds check
+var sboxArr = SBox()
+
 // table 2
 var shuf = []int{5, 0, 1, 4, 7, 12, 3, 8, 13, 6, 9, 2, 15, 10, 11, 14}
 var shufinv = []int{1, 2, 11, 6, 3, 0, 9, 4, 7, 10, 13, 14, 5, 8, 15, 12}
 
+// SBox returns the 4-bit S-box S of table 1.
+func SBox() [16]byte {
+	var s [16]byte
+	copy(s[:], sbox)
+	return s
+}
+
+// InvSBox returns the inverse of the S-box.  The Feistel structure of TWINE
+// never evaluates S^-1, but hardware and analysis work sometimes needs it.
+func InvSBox() [16]byte {
+	var s [16]byte
+	for x, y := range sbox {
+		s[y] = byte(x)
+	}
+	return s
+}
+
+// Shuffle returns the block shuffle π of table 2: nybble h of the state
+// moves to position Shuffle()[h] at the end of each round.
+func Shuffle() [16]int {
+	var p [16]int
+	copy(p[:], shuf)
+	return p
+}
+
+// InvShuffle returns π^-1, the block shuffle used by decryption.  It is
+// derived from π rather than copied from the decryption table, so the two
+// can be checked against each other.
+func InvShuffle() [16]int {
+	var p [16]int
+	for h, v := range shuf {
+		p[v] = h
+	}
+	return p
+}
+
 // table 3
-var roundconst = []byte{
-	0x01, 0x02, 0x04, 0x08, 0x10, 0x20, 0x03, 0x06, 0x0c, 0x18, 0x30, 0x23, 0x05, 0x0a, 0x14, 0x28, 0x13, 0x26,
-	0x0f, 0x1e, 0x3c, 0x3b, 0x35, 0x29, 0x11, 0x22, 0x07, 0x0e, 0x1c, 0x38, 0x33, 0x25, 0x09, 0x12, 0x24, 0x0b,
+var roundconst = RoundConstants(36)
+
+// RoundConstants returns the first n round constants CON^1 ... CON^n.  CON^i
+// is the 6-bit state x^(i-1) of the LFSR defined by x^6 + x + 1, of which
+// TWINE uses the first 35.  Larger n is for experiments with extended round
+// counts; the sequence has period 63.
+func RoundConstants(n int) []byte {
+	con := make([]byte, n)
+	c := byte(1)
+	for i := range con {
+		con[i] = c
+		c <<= 1
+		if c&0x40 != 0 {
+			c ^= 0x43
+		}
+	}
+	return con
 }
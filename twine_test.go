@@ -47,3 +47,174 @@ func TestTWINE(t *testing.T) {
 		}
 	}
 }
+
+func TestRoundConstants(t *testing.T) {
+
+	// table 3 of the specification
+	want := []byte{
+		0x01, 0x02, 0x04, 0x08, 0x10, 0x20, 0x03, 0x06, 0x0c, 0x18, 0x30, 0x23, 0x05, 0x0a, 0x14, 0x28, 0x13, 0x26,
+		0x0f, 0x1e, 0x3c, 0x3b, 0x35, 0x29, 0x11, 0x22, 0x07, 0x0e, 0x1c, 0x38, 0x33, 0x25, 0x09, 0x12, 0x24, 0x0b,
+	}
+
+	if got := RoundConstants(36); !bytes.Equal(got, want) {
+		t.Errorf("RoundConstants(36)=% 02x\nwant % 02x", got, want)
+	}
+
+	// the LFSR is maximal length
+	con := RoundConstants(64)
+	for i := 1; i < 63; i++ {
+		if con[i] == con[0] {
+			t.Errorf("sequence repeats after %d steps", i)
+		}
+	}
+	if con[63] != con[0] {
+		t.Errorf("CON^64=%02x, want period 63", con[63])
+	}
+}
+
+func TestTableExports(t *testing.T) {
+
+	s, si := SBox(), InvSBox()
+	for x := 0; x < 16; x++ {
+		if si[s[x]] != byte(x) || s[si[x]] != byte(x) {
+			t.Errorf("InvSBox is not the inverse of SBox at %x", x)
+		}
+	}
+
+	p, pi := Shuffle(), InvShuffle()
+	for h := 0; h < 16; h++ {
+		if pi[p[h]] != h || p[pi[h]] != h {
+			t.Errorf("InvShuffle is not the inverse of Shuffle at %d", h)
+		}
+		if pi[h] != shufinv[h] {
+			t.Errorf("InvShuffle()[%d]=%d, decryption table has %d", h, pi[h], shufinv[h])
+		}
+	}
+
+	// the returned tables are copies
+	s[0] ^= 1
+	if SBox() == s {
+		t.Error("SBox returned the package table")
+	}
+}
+
+func TestMultiBlock(t *testing.T) {
+
+	for _, tst := range tests {
+
+		b, _ := New(tst.key)
+		m := b.(MultiBlock)
+
+		src := make([]byte, 8*33)
+		for i := range src {
+			src[i] = byte(i * 13)
+		}
+
+		want := make([]byte, len(src))
+		for i := 0; i < len(src); i += 8 {
+			b.Encrypt(want[i:], src[i:i+8])
+		}
+
+		got := make([]byte, len(src))
+		m.EncryptBlocks(got, src)
+		if !bytes.Equal(got, want) {
+			t.Errorf("EncryptBlocks differs from Encrypt")
+		}
+
+		m.DecryptBlocks(got, got)
+		if !bytes.Equal(got, src) {
+			t.Errorf("DecryptBlocks failed")
+		}
+	}
+}
+
+// FuzzBackends checks every backend, and EncryptBlocks where a backend
+// implements MultiBlock, against the default cipher's Encrypt.
+func FuzzBackends(f *testing.F) {
+
+	for _, tst := range tests {
+		f.Add(tst.key, tst.plain)
+		f.Add(tst.key, bytes.Repeat(tst.plain, bitsliceBlocks+3))
+	}
+
+	f.Fuzz(func(t *testing.T, key, data []byte) {
+		if len(key) != 10 && len(key) != 16 {
+			return
+		}
+		data = data[:len(data)&^7]
+
+		ref, err := New(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := make([]byte, len(data))
+		for i := 0; i < len(data); i += 8 {
+			ref.Encrypt(want[i:], data[i:i+8])
+		}
+
+		got := make([]byte, len(data))
+		for _, be := range raceBackends {
+			b, err := New(key, be.opts...)
+			if err != nil {
+				t.Fatalf("%s: %v", be.name, err)
+			}
+			for i := 0; i < len(data); i += 8 {
+				b.Encrypt(got[i:], data[i:i+8])
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("%s: Encrypt differs from the default", be.name)
+			}
+			for i := 0; i < len(data); i += 8 {
+				b.Decrypt(got[i:], got[i:i+8])
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("%s: Decrypt does not invert Encrypt", be.name)
+			}
+
+			m, ok := b.(MultiBlock)
+			if !ok {
+				continue
+			}
+			m.EncryptBlocks(got, data)
+			if !bytes.Equal(got, want) {
+				t.Errorf("%s: EncryptBlocks differs from the default", be.name)
+			}
+			m.DecryptBlocks(got, got)
+			if !bytes.Equal(got, data) {
+				t.Errorf("%s: DecryptBlocks does not invert EncryptBlocks", be.name)
+			}
+		}
+	})
+}
+
+func TestParams(t *testing.T) {
+
+	for _, tst := range tests {
+		b, _ := New(tst.key)
+		p := b.(interface{ Params() Params }).Params()
+
+		if p.BlockSize != b.BlockSize() || p.KeySize != len(tst.key) || p.SecurityBits != 8*len(tst.key) || p.Rounds != 36 {
+			t.Errorf("unexpected params for %d-byte key: %+v", len(tst.key), p)
+		}
+		if p.MaxBytesPerKey != 1<<23 {
+			t.Errorf("MaxBytesPerKey=%d, want 2^23", p.MaxBytesPerKey)
+		}
+		if len(p.RecommendedModes) == 0 || p.RecommendedModes[0] != "SIV" {
+			t.Errorf("RecommendedModes=%v", p.RecommendedModes)
+		}
+	}
+}
+
+func TestPackedShuffle(t *testing.T) {
+	// nybble h of x holds h
+	x := uint64(0x0123456789abcdef)
+	y := shuffle(x)
+	for h, p := range shuf {
+		if got := int(y>>(60-4*uint(p))) & 0x0f; got != h {
+			t.Errorf("shuffle: position %d holds nybble %d, want %d", p, got, h)
+		}
+	}
+	if z := unshuffle(y); z != x {
+		t.Errorf("unshuffle(shuffle(x)) = %016x, want %016x", z, x)
+	}
+}
@@ -0,0 +1,76 @@
+package twine
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestVarBlock(t *testing.T) {
+
+	key := []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x99, 0xAA, 0xBB, 0xCC, 0xDD, 0xEE, 0xFF}
+
+	v, err := NewVarBlock(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for n := MinVarBlockSize; n <= MaxVarBlockSize; n++ {
+		p := make([]byte, n)
+		for i := range p {
+			p[i] = byte(i * 7)
+		}
+
+		c := make([]byte, n)
+		v.Encrypt(c, p)
+
+		if bytes.Equal(c, p) {
+			t.Errorf("n=%d: ciphertext equals plaintext", n)
+		}
+
+		got := make([]byte, n)
+		v.Decrypt(got, c)
+		if !bytes.Equal(got, p) {
+			t.Errorf("n=%d: decrypt failed:\ngot : % 02x\nwant: % 02x", n, got, p)
+		}
+
+		// in-place
+		v.Encrypt(got, got)
+		if !bytes.Equal(got, c) {
+			t.Errorf("n=%d: in-place encrypt mismatch", n)
+		}
+	}
+
+	// a single-byte domain must be a permutation
+	var seen [256]bool
+	for i := 0; i < 256; i++ {
+		var c [1]byte
+		v.Encrypt(c[:], []byte{byte(i)})
+		if seen[c[0]] {
+			t.Fatalf("single-byte output %02x repeated", c[0])
+		}
+		seen[c[0]] = true
+	}
+}
+
+func TestNewVarBlockFromReader(t *testing.T) {
+
+	key := tests[1].key
+	want, _ := NewVarBlock(key)
+	v, err := NewVarBlockFromReader(bytes.NewReader(key), len(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := []byte("variable length")
+	c1 := make([]byte, len(p))
+	c2 := make([]byte, len(p))
+	want.Encrypt(c1, p)
+	v.Encrypt(c2, p)
+	if !bytes.Equal(c1, c2) {
+		t.Errorf("NewVarBlockFromReader differs from NewVarBlock")
+	}
+
+	if _, err := NewVarBlockFromReader(bytes.NewReader(make([]byte, 12)), 12); err == nil {
+		t.Error("expected KeySizeError")
+	}
+}
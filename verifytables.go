@@ -0,0 +1,164 @@
+//go:build ignore
+
+// verifytables recomputes the properties that define the constant tables in
+// twine.go and exits non-zero if the hard-coded tables disagree with them.
+// It is run by go generate.  The tables derived from these at run time (the
+// S-box copies, the inverse shuffle, the round constants and the T-tables of
+// WithTables) are recomputed from their rules and compared entry by entry
+// when the package initialises; see selfcheck.go.
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"strconv"
+)
+
+func main() {
+
+	tables, err := parseTables("twine.go")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var failed bool
+	check := func(name string, err error) {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			failed = true
+		}
+	}
+
+	check("sbox", checkSbox(tables["sbox"]))
+	check("shuf", checkShuf(tables["shuf"], tables["shufinv"]))
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// parseTables returns the integer literals of every package-level slice
+// variable in file
+func parseTables(file string) (map[string][]int, error) {
+
+	f, err := parser.ParseFile(token.NewFileSet(), file, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	tables := make(map[string][]int)
+
+	for _, d := range f.Decls {
+		gd, ok := d.(*ast.GenDecl)
+		if !ok || gd.Tok != token.VAR {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			vs := spec.(*ast.ValueSpec)
+			for i, v := range vs.Values {
+				cl, ok := v.(*ast.CompositeLit)
+				if !ok {
+					continue
+				}
+				var vals []int
+				for _, e := range cl.Elts {
+					lit, ok := e.(*ast.BasicLit)
+					if !ok {
+						return nil, fmt.Errorf("%s: non-literal element", vs.Names[i].Name)
+					}
+					n, err := strconv.ParseInt(lit.Value, 0, 64)
+					if err != nil {
+						return nil, err
+					}
+					vals = append(vals, int(n))
+				}
+				tables[vs.Names[i].Name] = vals
+			}
+		}
+	}
+
+	return tables, nil
+}
+
+func isPermutation(t []int) error {
+	if len(t) != 16 {
+		return fmt.Errorf("has %d entries, want 16", len(t))
+	}
+	var seen [16]bool
+	for i, v := range t {
+		if v < 0 || v > 15 || seen[v] {
+			return fmt.Errorf("entry %d (%#x) breaks the permutation", i, v)
+		}
+		seen[v] = true
+	}
+	return nil
+}
+
+// checkSbox verifies the S-box is a permutation with the optimal differential
+// and linear properties claimed for it: no differential or linear
+// approximation holds with probability above 2^-2.
+func checkSbox(s []int) error {
+
+	if err := isPermutation(s); err != nil {
+		return err
+	}
+
+	for a := 1; a < 16; a++ {
+		var ddt [16]int
+		for x := 0; x < 16; x++ {
+			ddt[s[x]^s[x^a]]++
+		}
+		for b, n := range ddt {
+			if n > 4 {
+				return fmt.Errorf("differential %x->%x holds for %d/16 inputs", a, b, n)
+			}
+		}
+	}
+
+	parity := func(x int) int {
+		x ^= x >> 2
+		x ^= x >> 1
+		return x & 1
+	}
+
+	for a := 0; a < 16; a++ {
+		for b := 1; b < 16; b++ {
+			n := 0
+			for x := 0; x < 16; x++ {
+				n += parity(a&x^b&s[x]) ^ 1
+			}
+			if n < 4 || n > 12 {
+				return fmt.Errorf("linear approximation %x.x = %x.S(x) holds for %d/16 inputs", a, b, n)
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkShuf verifies the block shuffle is a permutation moving even nybbles
+// to odd positions and vice versa, and that shufinv is its inverse
+func checkShuf(shuf, shufinv []int) error {
+
+	if err := isPermutation(shuf); err != nil {
+		return err
+	}
+	if err := isPermutation(shufinv); err != nil {
+		return fmt.Errorf("shufinv %v", err)
+	}
+
+	for i, v := range shuf {
+		if i%2 == v%2 {
+			return fmt.Errorf("nybble %d moved to %d, same parity", i, v)
+		}
+		if shufinv[v] != i {
+			return fmt.Errorf("shufinv[%d]=%d, want %d", v, shufinv[v], i)
+		}
+	}
+
+	return nil
+}
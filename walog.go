@@ -0,0 +1,191 @@
+package twine
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"io"
+)
+
+// A sealed log record is a 16-byte header, the record encrypted in CTR mode
+// and an 8-byte CMAC tag.  The header holds the log's random 8-byte ID and
+// the record's sequence number, with the top bit set on the end-of-log
+// record.  The tag covers the previous record's tag, the header and the
+// ciphertext, chaining the records so that dropping, reordering or splicing
+// them, within a log or between logs, breaks verification.  The end-of-log
+// record makes truncation detectable.
+//
+// The CTR counter blocks of a record are id + (seq<<32 | block), so logs
+// under one key use disjoint keystreams unless their random IDs fall within
+// their lengths of each other, as with any randomly started CTR.
+
+const (
+	logHeader   = 16
+	logOverhead = logHeader + 8
+	logEnd      = 1 << 63
+	logMaxSeq   = 1 << 32
+	logMaxLen   = 8 << 32
+)
+
+type logChain struct {
+	enc  cipher.Block
+	mac  hash.Hash
+	id   [8]byte
+	seq  uint64
+	prev [8]byte
+}
+
+func newLogChain(enc, mac cipher.Block) logChain {
+	return logChain{enc: enc, mac: NewCMAC(mac)}
+}
+
+// tag computes the tag of a record
+func (c *logChain) tag(hdr, ct []byte) []byte {
+	c.mac.Reset()
+	c.mac.Write(c.prev[:])
+	c.mac.Write(hdr)
+	c.mac.Write(ct)
+	return c.mac.Sum(nil)
+}
+
+func (c *logChain) ctr(seq uint64) cipher.Stream {
+	var iv [8]byte
+	binary.BigEndian.PutUint64(iv[:], binary.BigEndian.Uint64(c.id[:])+seq<<32)
+	return wrappingCTR(c.enc, iv[:])
+}
+
+// LogSealer seals the records of an append-only log, such as a device event
+// log, so they are confidential and tamper-evident.  enc and mac must be
+// keyed independently.  A LogSealer is not safe for concurrent use.
+type LogSealer struct {
+	c      logChain
+	closed bool
+}
+
+// NewLogSealer returns a LogSealer starting a new log, whose ID is read
+// from r.  If r is nil, crypto/rand.Reader is used.
+func NewLogSealer(enc, mac cipher.Block, r io.Reader) (*LogSealer, error) {
+	if r == nil {
+		r = rand.Reader
+	}
+	s := &LogSealer{c: newLogChain(enc, mac)}
+	if _, err := io.ReadFull(r, s.c.id[:]); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Seal returns the sealed form of the next record.  After 2^32 records it
+// returns ErrCounterOverflow and the log must be closed and restarted under a
+// new key.
+func (s *LogSealer) Seal(record []byte) ([]byte, error) {
+	if s.closed {
+		return nil, errors.New("twine: log is closed")
+	}
+	if s.c.seq == logMaxSeq {
+		return nil, ErrCounterOverflow
+	}
+	if uint64(len(record)) > logMaxLen {
+		return nil, errors.New("twine: log record too long")
+	}
+	return s.seal(record, s.c.seq), nil
+}
+
+// Close returns the end-of-log record, which must be stored after the last
+// record.  The sealer cannot be used afterwards.
+func (s *LogSealer) Close() []byte {
+	s.closed = true
+	return s.seal(nil, s.c.seq|logEnd)
+}
+
+func (s *LogSealer) seal(record []byte, hdr uint64) []byte {
+	out := make([]byte, logHeader+len(record), len(record)+logOverhead)
+	copy(out, s.c.id[:])
+	binary.BigEndian.PutUint64(out[8:], hdr)
+	s.c.ctr(s.c.seq).XORKeyStream(out[logHeader:], record)
+
+	tag := s.c.tag(out[:logHeader], out[logHeader:])
+	copy(s.c.prev[:], tag)
+	s.c.seq++
+
+	return append(out, tag...)
+}
+
+// LogOpener verifies and decrypts the records of a sealed log in order.
+type LogOpener struct {
+	c     logChain
+	ended bool
+	err   error // sticky failure
+}
+
+// NewLogOpener returns a LogOpener for a log sealed with the same ciphers.
+// The log's ID is taken from its first record.
+func NewLogOpener(enc, mac cipher.Block) *LogOpener {
+	return &LogOpener{c: newLogChain(enc, mac)}
+}
+
+// Open verifies the next sealed record and returns its plaintext.  It
+// returns io.EOF for a valid end-of-log record, ErrAuthFailed if the record
+// is forged or out of order, and ErrMalformedContainer if it is too short.
+// Once Open has failed, it returns the same error for any further record.
+func (o *LogOpener) Open(sealed []byte) ([]byte, error) {
+	return o.open(nil, sealed)
+}
+
+// open is Open, appending the plaintext to dst
+func (o *LogOpener) open(dst, sealed []byte) ([]byte, error) {
+
+	switch {
+	case o.err != nil:
+		return nil, o.err
+	case o.ended:
+		return nil, errors.New("twine: log already ended")
+	case len(sealed) < logOverhead:
+		o.err = ErrMalformedContainer
+		return nil, o.err
+	}
+
+	hdr := sealed[:logHeader]
+	ct := sealed[logHeader : len(sealed)-8]
+	tag := sealed[len(sealed)-8:]
+
+	if o.c.seq == 0 {
+		copy(o.c.id[:], hdr)
+	}
+	h := binary.BigEndian.Uint64(hdr[8:])
+	end := h&logEnd != 0
+	if subtle.ConstantTimeCompare(hdr[:8], o.c.id[:]) != 1 ||
+		h&^logEnd != o.c.seq || end && len(ct) != 0 ||
+		subtle.ConstantTimeCompare(o.c.tag(hdr, ct), tag) != 1 {
+		o.err = ErrAuthFailed
+		return nil, o.err
+	}
+
+	copy(o.c.prev[:], tag)
+	o.c.seq++
+
+	if end {
+		o.ended = true
+		return nil, io.EOF
+	}
+
+	ret, pt := sliceForAppend(dst, len(ct))
+	o.c.ctr(h).XORKeyStream(pt, ct)
+	return ret, nil
+}
+
+// Finish reports whether the log was read through its end-of-log record.  It
+// returns the error of a failed Open, or io.ErrUnexpectedEOF if the log was
+// truncated.
+func (o *LogOpener) Finish() error {
+	switch {
+	case o.err != nil:
+		return o.err
+	case !o.ended:
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package twine
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestLog(t *testing.T) {
+
+	enc, _ := New(tests[0].key)
+	mac, _ := New(tests[1].key)
+
+	records := [][]byte{[]byte("boot"), nil, bytes.Repeat([]byte("sensor reading "), 50), []byte("shutdown")}
+
+	s, err := NewLogSealer(enc, mac, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sealed [][]byte
+	for _, r := range records {
+		c, err := s.Seal(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(r) > 0 && bytes.Contains(c, r) {
+			t.Errorf("record %q not encrypted", r)
+		}
+		sealed = append(sealed, c)
+	}
+	sealed = append(sealed, s.Close())
+	if _, err := s.Seal(nil); err == nil {
+		t.Errorf("Seal after Close succeeded")
+	}
+
+	open := func(log [][]byte) ([][]byte, error) {
+		o := NewLogOpener(enc, mac)
+		var out [][]byte
+		for _, c := range log {
+			p, err := o.Open(c)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return out, err
+			}
+			out = append(out, p)
+		}
+		return out, o.Finish()
+	}
+
+	got, err := open(sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range records {
+		if !bytes.Equal(got[i], records[i]) {
+			t.Errorf("record %d = %q, want %q", i, got[i], records[i])
+		}
+	}
+
+	// truncated: end record missing
+	if _, err := open(sealed[:3]); err != io.ErrUnexpectedEOF {
+		t.Errorf("truncated log: err = %v, want io.ErrUnexpectedEOF", err)
+	}
+
+	// dropped, reordered and modified records
+	reordered := [][]byte{sealed[0], sealed[2], sealed[1], sealed[3], sealed[4]}
+	dropped := [][]byte{sealed[0], sealed[2], sealed[3], sealed[4]}
+	modified := make([][]byte, len(sealed))
+	copy(modified, sealed)
+	modified[2] = append([]byte(nil), sealed[2]...)
+	modified[2][20] ^= 1
+	for name, log := range map[string][][]byte{"reordered": reordered, "dropped": dropped, "modified": modified} {
+		if _, err := open(log); err != ErrAuthFailed {
+			t.Errorf("%s log: err = %v, want ErrAuthFailed", name, err)
+		}
+	}
+
+	if _, err := open([][]byte{sealed[0][:20]}); err != ErrMalformedContainer {
+		t.Errorf("short record: err = %v, want ErrMalformedContainer", err)
+	}
+
+	// a second log under the same keys has its own keystream, and its
+	// records cannot be spliced into the first
+	s2, _ := NewLogSealer(enc, mac, nil)
+	var other [][]byte
+	for _, r := range records {
+		c, _ := s2.Seal(r)
+		other = append(other, c)
+	}
+	other = append(other, s2.Close())
+	for i := range records {
+		if bytes.Equal(other[i][logHeader:], sealed[i][logHeader:]) {
+			t.Errorf("record %d: two logs under one key sealed it identically", i)
+		}
+	}
+	if bytes.Equal(other[0][logHeader:len(other[0])-8], sealed[0][logHeader:len(sealed[0])-8]) {
+		t.Errorf("two logs under one key share a keystream")
+	}
+	spliced := [][]byte{sealed[0], sealed[1], other[2], sealed[3], sealed[4]}
+	if _, err := open(spliced); err != ErrAuthFailed {
+		t.Errorf("spliced log: err = %v, want ErrAuthFailed", err)
+	}
+	if _, err := open(append(sealed[:4:4], other[4])); err != ErrAuthFailed {
+		t.Errorf("foreign end record: err = %v, want ErrAuthFailed", err)
+	}
+
+	if _, err := NewLogSealer(enc, mac, bytes.NewReader(nil)); err == nil {
+		t.Errorf("NewLogSealer succeeded without randomness")
+	}
+}
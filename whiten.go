@@ -0,0 +1,45 @@
+package twine
+
+import "crypto/cipher"
+
+// EncryptWhitened sets dst to E(src xor pre) xor post, the building block of
+// XEX-style tweakable constructions.  pre and post must be at least one
+// block long; dst and src may overlap entirely.
+func EncryptWhitened(b cipher.Block, dst, src, pre, post []byte) {
+	var x [8]byte
+	bs := whitenCheck(b, dst, src, pre, post)
+
+	for i := 0; i < bs; i++ {
+		x[i] = src[i] ^ pre[i]
+	}
+	b.Encrypt(x[:bs], x[:bs])
+	for i := 0; i < bs; i++ {
+		dst[i] = x[i] ^ post[i]
+	}
+}
+
+// DecryptWhitened inverts EncryptWhitened with the same masks: it sets dst
+// to D(src xor post) xor pre.
+func DecryptWhitened(b cipher.Block, dst, src, pre, post []byte) {
+	var x [8]byte
+	bs := whitenCheck(b, dst, src, pre, post)
+
+	for i := 0; i < bs; i++ {
+		x[i] = src[i] ^ post[i]
+	}
+	b.Decrypt(x[:bs], x[:bs])
+	for i := 0; i < bs; i++ {
+		dst[i] = x[i] ^ pre[i]
+	}
+}
+
+func whitenCheck(b cipher.Block, dst, src, pre, post []byte) int {
+	bs := b.BlockSize()
+	if bs > 8 {
+		panic("twine: whitening requires a 64-bit block cipher")
+	}
+	if len(dst) < bs || len(src) < bs || len(pre) < bs || len(post) < bs {
+		panic("twine: whitening input not full block")
+	}
+	return bs
+}
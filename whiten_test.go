@@ -0,0 +1,43 @@
+package twine
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWhitened(t *testing.T) {
+
+	for _, tst := range tests {
+		c, _ := New(tst.key)
+
+		zero := make([]byte, 8)
+		var ct [8]byte
+		EncryptWhitened(c, ct[:], tst.plain, zero, zero)
+		if !bytes.Equal(ct[:], tst.cipher) {
+			t.Errorf("zero masks: got % 02x, want % 02x", ct[:], tst.cipher)
+		}
+
+		pre := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+		post := []byte{0xf0, 0xe0, 0xd0, 0xc0, 0xb0, 0xa0, 0x90, 0x80}
+
+		// E(p ^ pre) ^ post by hand
+		var want [8]byte
+		for i := range want {
+			want[i] = tst.plain[i] ^ pre[i]
+		}
+		c.Encrypt(want[:], want[:])
+		for i := range want {
+			want[i] ^= post[i]
+		}
+
+		EncryptWhitened(c, ct[:], tst.plain, pre, post)
+		if ct != want {
+			t.Errorf("got % 02x, want % 02x", ct[:], want[:])
+		}
+
+		DecryptWhitened(c, ct[:], ct[:], pre, post)
+		if !bytes.Equal(ct[:], tst.plain) {
+			t.Errorf("decrypt got % 02x, want % 02x", ct[:], tst.plain)
+		}
+	}
+}
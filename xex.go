@@ -0,0 +1,133 @@
+package twine
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+
+	"github.com/dgryski/go-twine/gf64"
+)
+
+// XEX encrypts data units, such as flash pages or disk sectors, under a
+// 64-bit tweak, usually the unit's address, so that equal data at different
+// addresses encrypts differently.  Block j of a unit is encrypted as
+// E(P xor D) xor D with D = 2^j * E2(tweak) in GF(2^64), where E2 is the
+// tweak cipher; units whose length is not a multiple of the block size are
+// handled with ciphertext stealing, as in IEEE 1619 XTS.  When one cipher
+// encrypts both data and tweaks, D = 2^(j+1) * E(tweak): the first block's
+// mask would otherwise be E(tweak) itself, and decrypting a zero block would
+// reveal it.  There is no standard for 64-bit blocks, so the output
+// interoperates only with this package.
+//
+// XEX provides confidentiality only: it is length-preserving, so it cannot
+// detect tampering, and rewriting a unit with data it held before is
+// visible.  Rekey well before 2^32 blocks have been encrypted.
+type XEX struct {
+	b, tweak  cipher.Block
+	singleKey bool // masks start at 2 * E(tweak)
+	wiped     bool
+}
+
+// NewXEX returns an XEX encrypting both the data and the tweak with b,
+// which must have a 64-bit block.
+func NewXEX(b cipher.Block) *XEX {
+	if b.BlockSize() != 8 {
+		panic("twine: XEX requires 64-bit block ciphers")
+	}
+	return &XEX{b: b, tweak: b, singleKey: true}
+}
+
+// NewXTS returns the two-key variant of XEX, which encrypts data with b and
+// tweaks with tweak.  The two ciphers must be keyed independently; as in
+// XTS, equal keys leak the masks, and passing the same cipher twice panics.
+// Use NewXEX for a single key.
+func NewXTS(b, tweak cipher.Block) *XEX {
+	if b.BlockSize() != 8 || tweak.BlockSize() != 8 {
+		panic("twine: XEX requires 64-bit block ciphers")
+	}
+	if b == tweak {
+		panic("twine: XTS requires independent data and tweak ciphers")
+	}
+	return &XEX{b: b, tweak: tweak}
+}
+
+// Wipe marks the XEX as wiped; it holds no key material of its own.  The
+// XEX must not be used afterwards: Encrypt and Decrypt panic.
+func (x *XEX) Wipe() {
+	x.wiped = true
+}
+
+// mask returns the mask of the first block
+func (x *XEX) mask(tweak uint64) uint64 {
+	var t [8]byte
+	binary.BigEndian.PutUint64(t[:], tweak)
+	x.tweak.Encrypt(t[:], t[:])
+	m := binary.BigEndian.Uint64(t[:])
+	if x.singleKey {
+		m = gf64.Double(m)
+	}
+	return m
+}
+
+// Encrypt encrypts the data unit src, which must be at least one block
+// long, under tweak into dst.  dst and src may overlap entirely.
+func (x *XEX) Encrypt(dst, src []byte, tweak uint64) {
+	x.crypt(dst, src, tweak, false)
+}
+
+// Decrypt decrypts the data unit src under tweak into dst.  dst and src may
+// overlap entirely.
+func (x *XEX) Decrypt(dst, src []byte, tweak uint64) {
+	x.crypt(dst, src, tweak, true)
+}
+
+func (x *XEX) crypt(dst, src []byte, tweak uint64, decrypt bool) {
+	if x.wiped {
+		panic(errWipedMode)
+	}
+	if len(src) < 8 {
+		panic("twine: XEX data unit shorter than a block")
+	}
+	if len(dst) < len(src) {
+		panic("twine: output smaller than input")
+	}
+
+	fn := EncryptWhitened
+	if decrypt {
+		fn = DecryptWhitened
+	}
+
+	var d [8]byte
+	m := x.mask(tweak)
+	full := len(src) / 8
+	r := len(src) % 8
+	if r != 0 {
+		// the last full block is processed with the stealing below
+		full--
+	}
+
+	for i := 0; i < full; i++ {
+		binary.BigEndian.PutUint64(d[:], m)
+		fn(x.b, dst[8*i:], src[8*i:], d[:], d[:])
+		m = gf64.Double(m)
+	}
+	if r == 0 {
+		return
+	}
+
+	// ciphertext stealing: when decrypting, the last full block was
+	// encrypted with the following block's mask
+	m1, m2 := m, gf64.Double(m)
+	if decrypt {
+		m1, m2 = m2, m1
+	}
+
+	var cc, pp [8]byte
+	last := src[8*full:]
+	binary.BigEndian.PutUint64(d[:], m1)
+	fn(x.b, cc[:], last, d[:], d[:])
+	copy(pp[:], last[8:])
+	copy(pp[r:], cc[r:])
+	copy(dst[8*full+8:], cc[:r])
+	binary.BigEndian.PutUint64(d[:], m2)
+	fn(x.b, dst[8*full:], pp[:], d[:], d[:])
+}
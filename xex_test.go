@@ -0,0 +1,105 @@
+package twine
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/dgryski/go-twine/gf64"
+)
+
+// xexRef encrypts a whole number of blocks from the definition of XEX,
+// with the mask of block j 2^(j+start) * E(tweak)
+func xexRef(b, tb *twineCipher, src []byte, tweak uint64, start int) []byte {
+	var t [8]byte
+	binary.BigEndian.PutUint64(t[:], tweak)
+	tb.Encrypt(t[:], t[:])
+	m := binary.BigEndian.Uint64(t[:])
+	for i := 0; i < start; i++ {
+		m = gf64.Double(m)
+	}
+
+	out := make([]byte, len(src))
+	for i := 0; i < len(src); i += 8 {
+		x := binary.BigEndian.Uint64(src[i:]) ^ m
+		binary.BigEndian.PutUint64(out[i:], x)
+		b.Encrypt(out[i:], out[i:])
+		binary.BigEndian.PutUint64(out[i:], binary.BigEndian.Uint64(out[i:])^m)
+		m = gf64.Double(m)
+	}
+	return out
+}
+
+func TestXEX(t *testing.T) {
+
+	b, _ := New(tests[0].key)
+	tb, _ := New(tests[1].key)
+
+	page := make([]byte, 64)
+	for i := range page {
+		page[i] = byte(i)
+	}
+
+	for name, x := range map[string]*XEX{"XEX": NewXEX(b), "XTS": NewXTS(b, tb)} {
+		start := 0
+		if x.singleKey {
+			start = 1
+		}
+		want := xexRef(b.(*twineCipher), x.tweak.(*twineCipher), page, 7, start)
+		got := make([]byte, len(page))
+		x.Encrypt(got, page, 7)
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: Encrypt = %x, want %x", name, got, want)
+		}
+
+		other := make([]byte, len(page))
+		x.Encrypt(other, page, 8)
+		if bytes.Equal(other, got) {
+			t.Errorf("%s: tweak ignored", name)
+		}
+
+		for _, n := range []int{8, 9, 15, 16, 17, 63, 64} {
+			buf := append([]byte(nil), page[:n]...)
+			x.Encrypt(buf, buf, 1234)
+
+			// whole blocks match the reference; with stealing, the
+			// partial block is the head of the last whole block's
+			// encryption
+			whole := n &^ 7
+			ref := xexRef(b.(*twineCipher), x.tweak.(*twineCipher), page[:whole], 1234, start)
+			if n == whole && !bytes.Equal(buf, ref) {
+				t.Errorf("%s: %d bytes: Encrypt = %x, want %x", name, n, buf, ref)
+			}
+			if n != whole && (!bytes.Equal(buf[:whole-8], ref[:whole-8]) || !bytes.Equal(buf[whole:], ref[whole-8:n-8])) {
+				t.Errorf("%s: %d bytes: Encrypt = %x, reference %x", name, n, buf, ref)
+			}
+
+			x.Decrypt(buf, buf, 1234)
+			if !bytes.Equal(buf, page[:n]) {
+				t.Errorf("%s: %d bytes: round trip = %x", name, n, buf)
+			}
+		}
+	}
+
+	mustPanic(t, "short unit", func() { NewXEX(b).Encrypt(make([]byte, 7), make([]byte, 7), 0) })
+	mustPanic(t, "XTS with one cipher", func() { NewXTS(b, b) })
+
+	// with one key, decrypting a zero block must not reveal the mask E(T),
+	// which would give an encryption oracle
+	var tweak uint64 = 0x0123456789abcdef
+	var zero, p, et [8]byte
+	binary.BigEndian.PutUint64(et[:], tweak)
+	b.Encrypt(et[:], et[:])
+	NewXEX(b).Decrypt(p[:], zero[:], tweak)
+	for i := range p {
+		p[i] ^= byte(tweak >> (56 - 8*uint(i)))
+	}
+	if p == et {
+		t.Error("XEX: Decrypt(0, T) reveals E(T)")
+	}
+
+	x := NewXTS(b, tb)
+	var w Wiper = x
+	w.Wipe()
+	mustPanic(t, "XTS Encrypt after Wipe", func() { x.Encrypt(make([]byte, 8), make([]byte, 8), 0) })
+}